@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"github.com/prokoma/remote-tail-f/tailer"
+)
+
+// resumeAtTime moves t to the lines after its checkpoint time in
+// -checkpoint-mode time. Sources that cannot be searched by time, like HTTP
+// servers without range support, are read again from the start, the lines
+// output before are then dropped by timeResume.
+func resumeAtTime(ctx context.Context, t tailer.Tailer) error {
+	err := t.SetPositionAtTime(ctx, t.CheckpointTime(), *timeLayout)
+	if err == nil {
+		return nil
+	}
+	return t.SetPosition(ctx, 0, io.SeekStart)
+}
+
+// timeResume drops the lines that were output before a restart in
+// -checkpoint-mode time: every line until the first one timestamped after
+// the checkpoint time. Lines without a timestamp go with the line before.
+type timeResume struct {
+	since    time.Time
+	skipping bool
+}
+
+func newTimeResume(since time.Time) *timeResume {
+	return &timeResume{since: since, skipping: !since.IsZero()}
+}
+
+func (r *timeResume) filter(lines []tailer.Line) []tailer.Line {
+	if !r.skipping {
+		return lines
+	}
+	for i, line := range lines {
+		ts, ok := tailer.ParseLineTimestamp(line.Text, *timeLayout)
+		if ok && ts.After(r.since) {
+			r.skipping = false
+			return lines[i:]
+		}
+	}
+	return nil
+}
+
+// lastLineTime returns the timestamp of the last line carrying one.
+func lastLineTime(lines []tailer.Line) (time.Time, bool) {
+	for i := len(lines) - 1; i >= 0; i-- {
+		ts, ok := tailer.ParseLineTimestamp(lines[i].Text, *timeLayout)
+		if ok {
+			return ts, true
+		}
+	}
+	return time.Time{}, false
+}
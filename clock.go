@@ -0,0 +1,22 @@
+package main
+
+import "time"
+
+// clock is where the polling loop gets the time from, so it can be replaced
+// to drive intervals, retries, heartbeats and -duration without waiting.
+type clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
+
+var clk clock = realClock{}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// envPrefix starts the environment variables setting flags, e.g.
+// RTF_INTERVAL_SEC for -interval-sec.
+const envPrefix = "RTF_"
+
+func envName(flagName string) string {
+	return envPrefix + strings.ToUpper(strings.ReplaceAll(flagName, "-", "_"))
+}
+
+// loadFlagDefaults fills the flags not given on the command line from the
+// environment and then from the config file.
+func loadFlagDefaults(configPath string) error {
+	if configPath == "" {
+		configPath = os.Getenv(envName("config"))
+	}
+	config := map[string]any{}
+	if configPath != "" {
+		data, err := os.ReadFile(configPath)
+		if err != nil {
+			return fmt.Errorf("could not read config: %v", err)
+		}
+		err = yaml.Unmarshal(data, &config)
+		if err != nil {
+			return fmt.Errorf("invalid config %s: %v", configPath, err)
+		}
+		for name := range config {
+			if flag.Lookup(name) == nil || name == "config" {
+				return fmt.Errorf("unknown option in config %s: %s", configPath, name)
+			}
+		}
+	}
+
+	set := map[string]bool{}
+	flag.Visit(func(f *flag.Flag) { set[f.Name] = true })
+
+	var err error
+	flag.VisitAll(func(f *flag.Flag) {
+		if err != nil || set[f.Name] || f.Name == "config" {
+			return
+		}
+		if value, ok := os.LookupEnv(envName(f.Name)); ok {
+			if setErr := f.Value.Set(value); setErr != nil {
+				err = fmt.Errorf("invalid %s: %v", envName(f.Name), setErr)
+			}
+			return
+		}
+		if value, ok := config[f.Name]; ok {
+			// A list sets a repeatable flag once per element.
+			values, isList := value.([]any)
+			if !isList {
+				values = []any{value}
+			}
+			for _, v := range values {
+				if setErr := f.Value.Set(configString(v)); setErr != nil {
+					err = fmt.Errorf("invalid %s in config: %v", f.Name, setErr)
+					return
+				}
+			}
+		}
+	})
+	return err
+}
+
+// configString formats a config value the way it would be given on the
+// command line; fmt.Sprint would write large floats like 1e+06.
+func configString(value any) string {
+	if f, ok := value.(float64); ok {
+		return strconv.FormatFloat(f, 'f', -1, 64)
+	}
+	return fmt.Sprint(value)
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestConfigSetsFlags(t *testing.T) {
+	saved := flag.CommandLine
+	t.Cleanup(func() { flag.CommandLine = saved })
+	flag.CommandLine = flag.NewFlagSet("remote-tail-f", flag.ContinueOnError)
+	var rules stringList
+	flag.Var(&rules, "redact", "")
+	interval := flag.Int("interval-sec", 1, "")
+	ratio := flag.Float64("ratio", 0, "")
+
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	config := "redact:\n  - password=***\n  - token=***\ninterval-sec: 1e6\nratio: 0.5\n"
+	if err := os.WriteFile(configPath, []byte(config), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := loadFlagDefaults(configPath); err != nil {
+		t.Fatal(err)
+	}
+	if want := (stringList{"password=***", "token=***"}); !slices.Equal(rules, want) {
+		t.Errorf("got -redact %q, want %q", rules, want)
+	}
+	if *interval != 1000000 || *ratio != 0.5 {
+		t.Errorf("got -interval-sec %d, -ratio %v", *interval, *ratio)
+	}
+}
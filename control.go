@@ -0,0 +1,102 @@
+package main
+
+import (
+	"encoding/json"
+	"maps"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/prokoma/remote-tail-f/tailer"
+)
+
+// runStatus is shared by the fetch loop, the output and the control endpoint.
+type runStatus struct {
+	mu           sync.Mutex
+	offsets      map[string]int64 // read position by target
+	lastSuccess  time.Time
+	lastError    string
+	linesEmitted int64
+	bytesRead    int64
+	fetchErrors  int64
+	resets       int              // number of resets requested
+	events       map[string]int64 // counts by event kind, e.g. range-not-supported
+	droppedLines int64            // lines -output-fifo, -queue-full, -webhook or -otlp-endpoint dropped
+	queuedLines  int64            // lines waiting in the -queue-lines queue
+	spilledLines int64            // of which spilled to disk
+
+	errorsByCategory map[string]int64 // fetch errors by errorCategory
+}
+
+func (s *runStatus) countEvent(kind tailer.EventKind) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.events[kind.String()]++
+}
+
+func (s *runStatus) resetCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.resets
+}
+
+// takeReset reports whether a reset was requested since the caller last
+// looked, seen holds the number of resets it already handled. Every fetch
+// loop resets its own target.
+func (s *runStatus) takeReset(seen *int) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	requested := s.resets != *seen
+	*seen = s.resets
+	return requested
+}
+
+// newControlHandler serves GET /status and POST /reset, which restarts the
+// file from offset 0 at the next poll.
+func newControlHandler(status *runStatus) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /status", func(w http.ResponseWriter, r *http.Request) {
+		status.mu.Lock()
+		var offset int64
+		for _, targetOffset := range status.offsets {
+			offset += targetOffset
+		}
+		body := struct {
+			Offset       int64            `json:"offset"`
+			Offsets      map[string]int64 `json:"offsets"`
+			LastSuccess  *time.Time       `json:"lastSuccess"`
+			LastError    string           `json:"lastError"`
+			LinesEmitted int64            `json:"linesEmitted"`
+			Events       map[string]int64 `json:"events"`
+			DroppedLines int64            `json:"droppedLines"`
+			FetchErrors  map[string]int64 `json:"fetchErrors"`
+			QueuedLines  int64            `json:"queuedLines"`
+			SpilledLines int64            `json:"spilledLines"`
+		}{
+			Offset:       offset,
+			Offsets:      maps.Clone(status.offsets),
+			LastError:    status.lastError,
+			LinesEmitted: status.linesEmitted,
+			Events:       maps.Clone(status.events),
+			DroppedLines: status.droppedLines,
+			FetchErrors:  maps.Clone(status.errorsByCategory),
+			QueuedLines:  status.queuedLines,
+			SpilledLines: status.spilledLines,
+		}
+		if !status.lastSuccess.IsZero() {
+			lastSuccess := status.lastSuccess
+			body.LastSuccess = &lastSuccess
+		}
+		status.mu.Unlock()
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(body)
+	})
+	mux.HandleFunc("POST /reset", func(w http.ResponseWriter, r *http.Request) {
+		status.mu.Lock()
+		status.resets++
+		status.mu.Unlock()
+		w.WriteHeader(http.StatusAccepted)
+	})
+	return mux
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/prokoma/remote-tail-f/tailer"
+)
+
+// Categories of fetch errors, shown in logs and counted in the status.
+var errorCategories = []string{"dns", "refused", "tls", "timeout", "connect", "auth", "not-found", "http-4xx", "http-5xx", "truncated", "other"}
+
+// errorCategory tells what kind of failure err is.
+func errorCategory(err error) string {
+	var dnsErr *net.DNSError
+	var statusErr *tailer.StatusError
+	var netErr net.Error
+	switch {
+	case errors.As(err, &dnsErr):
+		return "dns"
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return "refused"
+	case isTLSError(err):
+		return "tls"
+	case errors.Is(err, context.DeadlineExceeded) || errors.As(err, &netErr) && netErr.Timeout():
+		return "timeout"
+	case errors.Is(err, tailer.ErrAuth):
+		return "auth"
+	case errors.Is(err, os.ErrNotExist):
+		return "not-found"
+	case errors.Is(err, tailer.ErrTruncated):
+		return "truncated"
+	case errors.As(err, &statusErr) && statusErr.StatusCode >= 500:
+		return "http-5xx"
+	case errors.As(err, &statusErr) && statusErr.StatusCode >= 400:
+		return "http-4xx"
+	case errors.Is(err, tailer.ErrConnect):
+		return "connect"
+	default:
+		return "other"
+	}
+}
+
+func isTLSError(err error) bool {
+	var recordErr tls.RecordHeaderError
+	var alertErr tls.AlertError
+	var verifyErr *tls.CertificateVerificationError
+	var authorityErr x509.UnknownAuthorityError
+	var hostnameErr x509.HostnameError
+	var invalidErr x509.CertificateInvalidError
+	return errors.As(err, &recordErr) || errors.As(err, &alertErr) || errors.As(err, &verifyErr) ||
+		errors.As(err, &authorityErr) || errors.As(err, &hostnameErr) || errors.As(err, &invalidErr)
+}
+
+// retryPolicy is the -retry-policy: how long to wait before polling again
+// after an error of a category, or failFast to stop. Categories not listed
+// wait -interval-sec.
+type retryPolicy map[string]time.Duration
+
+const failFast time.Duration = -1
+
+// parseRetryPolicy reads rules like "http-5xx=5s,dns=1m,auth=fail".
+func parseRetryPolicy(value string) (retryPolicy, error) {
+	policy := retryPolicy{}
+	for _, rule := range splitList(value) {
+		category, delay, ok := strings.Cut(rule, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid retry policy rule %q, expected CATEGORY=DURATION or CATEGORY=fail", rule)
+		}
+		if category == "truncated" {
+			return nil, fmt.Errorf("truncation is handled through -on-truncate")
+		}
+		if !slices.Contains(errorCategories, category) {
+			return nil, fmt.Errorf("unknown error category %q, known are: %s", category, strings.Join(errorCategories, ", "))
+		}
+		if delay == "fail" {
+			policy[category] = failFast
+			continue
+		}
+		d, err := time.ParseDuration(delay)
+		if err != nil || d < 0 {
+			return nil, fmt.Errorf("invalid retry delay %q for %s", delay, category)
+		}
+		policy[category] = d
+	}
+	return policy, nil
+}
+
+// delay returns how long to wait after err instead of interval, and false
+// when the policy says to give up.
+func (p retryPolicy) delay(err error, interval time.Duration) (time.Duration, bool) {
+	d, ok := p[errorCategory(err)]
+	switch {
+	case !ok:
+		return interval, true
+	case d == failFast:
+		return 0, false
+	default:
+		return d, true
+	}
+}
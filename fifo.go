@@ -0,0 +1,129 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"time"
+)
+
+const (
+	fifoBufferLines  = 10000
+	fifoStallTimeout = 5 * time.Second
+)
+
+// fifoOutput is the -output-fifo sink. Lines are queued and written to the
+// named pipe by a goroutine, which opens it again whenever the reader went
+// away. When the queue is full and the reader made no progress for
+// fifoStallTimeout, the oldest lines are dropped so fetching goes on.
+type fifoOutput struct {
+	path    string
+	status  *runStatus
+	lines   chan []byte
+	partial []byte // start of a line not written completely yet
+	stalled bool
+	done    chan struct{}
+}
+
+func openFifoOutput(path string, status *runStatus) (*fifoOutput, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not open output FIFO: %v", err)
+	}
+	if info.Mode()&os.ModeNamedPipe == 0 {
+		return nil, fmt.Errorf("%s is not a named pipe", path)
+	}
+	f := &fifoOutput{
+		path:   path,
+		status: status,
+		lines:  make(chan []byte, fifoBufferLines),
+		done:   make(chan struct{}),
+	}
+	go f.run()
+	return f, nil
+}
+
+// Write queues the complete lines of p, it never fails.
+func (f *fifoOutput) Write(p []byte) (int, error) {
+	f.partial = append(f.partial, p...)
+	for {
+		i := bytes.IndexByte(f.partial, '\n')
+		if i < 0 {
+			break
+		}
+		f.enqueue(bytes.Clone(f.partial[:i+1]))
+		f.partial = f.partial[i+1:]
+	}
+	return len(p), nil
+}
+
+func (f *fifoOutput) enqueue(line []byte) {
+	select {
+	case f.lines <- line:
+		f.stalled = false
+		return
+	default:
+	}
+	if !f.stalled {
+		timer := time.NewTimer(fifoStallTimeout)
+		defer timer.Stop()
+		select {
+		case f.lines <- line:
+			return
+		case <-timer.C:
+			f.stalled = true
+		}
+	}
+	// Only this goroutine adds lines, so there is room after taking one.
+	select {
+	case <-f.lines:
+		f.countDropped(1)
+	default:
+	}
+	f.lines <- line
+}
+
+func (f *fifoOutput) countDropped(n int64) {
+	f.status.mu.Lock()
+	f.status.droppedLines += n
+	f.status.mu.Unlock()
+}
+
+func (f *fifoOutput) run() {
+	defer close(f.done)
+	var pipe *os.File
+	for line := range f.lines {
+		if pipe == nil {
+			var err error
+			// Opening blocks until there is a reader.
+			pipe, err = os.OpenFile(f.path, os.O_WRONLY, 0)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Failed to open output FIFO: %v\n", err)
+				f.countDropped(1)
+				time.Sleep(time.Second)
+				continue
+			}
+		}
+		_, err := pipe.Write(line)
+		if err != nil {
+			// The reader went away, wait for the next one.
+			pipe.Close()
+			pipe = nil
+			f.countDropped(1)
+		}
+	}
+	if pipe != nil {
+		pipe.Close()
+	}
+}
+
+// Close writes out the queued lines, giving up when the reader doesn't take
+// them within fifoStallTimeout.
+func (f *fifoOutput) Close() error {
+	close(f.lines)
+	select {
+	case <-f.done:
+	case <-time.After(fifoStallTimeout):
+	}
+	return nil
+}
@@ -1,126 +1,1264 @@
 package main
 
 import (
+	"bufio"
+	"context"
 	"errors"
 	"flag"
 	"fmt"
+	"io"
+	"net"
+	"net/http"
 	"net/url"
 	"os"
+	"os/signal"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
 	"time"
+
+	"github.com/prokoma/remote-tail-f/tailer"
+	"golang.org/x/net/http/httpguts"
 )
 
+// version is reported in the default User-Agent, set it at build time with
+// -ldflags "-X main.version=...".
+var version = "dev"
+
 var (
+	configPath        = flag.String("config", "", "YAML file with option defaults, e.g. \"interval-sec: 5\"; options can also be set through RTF_ environment variables like RTF_INTERVAL_SEC")
 	intervalSec       = flag.Int("interval-sec", 15, "Number of seconds between checks")
-	requestTimeoutSec = flag.Int("request-timeout-sec", 5, "Request timeout in seconds")
+	catchupMinBytes   = flag.Int64("catchup-min-bytes", 0, "Poll again after -catchup-interval instead of -interval-sec while polls return at least this many bytes, to catch up with a backlog quickly, e.g. the -chunk-bytes value (0 disables it)")
+	catchupInterval   = flag.Duration("catchup-interval", 0, "Delay between polls while catching up, see -catchup-min-bytes")
+	requestTimeoutSec = flag.Int("request-timeout-sec", 5, "Request timeout in seconds; over HTTP it bounds connecting and waiting for the response headers, over SFTP every operation")
+	httpVersion       = flag.String("http-version", "", "Pin the HTTP protocol to 1.1 or 2 (https only) instead of negotiating it")
+	maxIdleConns      = flag.Int("max-idle-conns", 0, "Keep at most this many idle HTTP connections open for the next polls (0 uses the net/http default)")
+	idleConnTimeout   = flag.Duration("idle-conn-timeout", 0, "Close idle HTTP connections kept for the next polls after this long, e.g. below the server's keep-alive timeout (0 uses the net/http default of 90s)")
+	readTimeoutSec    = flag.Int("read-timeout-sec", 0, "Over HTTP, give up a download when no data arrived for this many seconds, so a slow but progressing one goes on (0 uses -request-timeout-sec)")
 	stateFilePath     = flag.String("state-file", "", "Path to store state persistently")
+	stateDir          = flag.String("state-dir", "", "Directory to store state persistently, in a file named after a hash of the URL")
+	reset             = flag.Bool("reset", false, "Ignore saved state and start at the beginning of the file")
+	fromOffset        = flag.Int64("from-offset", -1, "Ignore saved state and start at this byte offset")
+	fromEnd           = flag.Bool("from-end", false, "Ignore saved state and start at the current end of the file")
+	onTruncate        = flag.String("on-truncate", "reset", "What to do when a file got shorter than the read position: reset to read it again from the start, skip to go on from its new end, or error to stop")
+	defaultStart      = flag.String("default-start", "beginning", "Where to start when there is no saved state and no start position is given: beginning or end of the file")
+	lastLines         = flag.Int("lines", -1, "Ignore saved state and start with the last N lines of the file")
+	since             = flag.String("since", "", "Ignore saved state and start at the first line timestamped at or after this time (timestamps must not decrease through the file)")
+	timeLayout        = flag.String("time-layout", time.RFC3339, "Go time layout of the timestamp at the start of each line, used by -since")
+	once              = flag.Bool("once", false, "Fetch new lines once and exit")
+	retries           = flag.Int("retries", 0, "With -once or -no-follow, retry a failed connection or fetch this many times before giving up")
+	retryPolicyRules  = flag.String("retry-policy", "http-5xx=5s,dns=1m", "Comma-separated CATEGORY=DURATION rules for how long to wait after a failed poll instead of -interval-sec, or CATEGORY=fail to exit, e.g. auth=fail to stop on rejected credentials instead of polling on; categories: dns, refused, tls, timeout, connect, auth, not-found, http-4xx, http-5xx, other")
+	retryDelay        = flag.Duration("retry-delay", 5*time.Second, "Delay between retries")
+	noFollow          = flag.Bool("no-follow", false, "Print the whole current file and exit, without loading or saving state")
+	duration          = flag.Duration("duration", 0, "Exit after running for this long, e.g. 10m (0 runs until interrupted)")
+	maxLines          = flag.Int64("max-lines", 0, "Exit after printing this many lines (0 means no limit)")
+	multiline         = flag.String("multiline", "", "Join lines into records starting with a line matching this regular expression, e.g. '^\\d{4}-' to keep stack traces with their log line")
+	multilineSep      = flag.String("multiline-separator", "\n", "Separator between the lines of a -multiline record")
+	dedupWindow       = flag.Int("dedup-window", 0, "Don't print a line identical to one of the last N printed, as a safety net against lines read twice; legitimate repeats within the window are dropped too")
+	skipEmpty         = flag.Bool("skip-empty", false, "Don't print empty lines, after -trim is applied")
+	withOffset        = flag.Bool("with-offset", false, "Prefix every line with the byte offset it starts at and a tab; for globs the offset is within the line's file, for streams within the data received")
+	reverse           = flag.Bool("reverse", false, "Print the lines read last line first; only for bounded reads with -once, -no-follow, -head or -peek")
+	peek              = flag.Bool("peek", false, "Fetch and print the new lines once without saving state, to see what the next poll would print")
+	head              = flag.Int64("head", 0, "Print the next N lines from the current position and exit, reading only as much of the file as needed; state is not saved")
+	maxBytes          = flag.Int64("max-bytes", 0, "Exit after printing this many bytes (0 means no limit)")
+	inputEncoding     = flag.String("input-encoding", "", "Charset of the remote files, e.g. latin1, windows-1250 or shift_jis, converted to UTF-8 for output (default: UTF-8, used as is)")
+	trimMode          = flag.String("trim", "none", "Strip trailing characters from lines: none, cr, space or all")
+	binaryMode        = flag.String("binary", "warn", "What to do with content that looks binary: warn, skip or sanitize")
+	journalCommand    = flag.String("journal-command", "journalctl -f -o cat", "Command run on the remote host for ssh+journal:// URLs")
+	grpcCACert        = flag.String("grpc-ca-cert", "", "PEM file with the CA certificates to verify grpcs:// servers with, instead of the system ones")
+	execCommand       = flag.String("exec-command", "", "Command run on the remote host for ssh+exec:// URLs, e.g. \"tail -F /var/log/app.log\"")
+	stream            = flag.Bool("stream", false, "Keep one HTTP request open and read lines as the server streams them, for endpoints that behave like tail -f")
+	streamIdleTimeout = flag.Duration("stream-idle-timeout", 5*time.Minute, "With -stream, reconnect when nothing was received for this long (0 disables it)")
+	queryBody         = flag.String("query-body", "", "Query a log API with POST requests carrying this JSON body instead of fetching a file; {{cursor}} is replaced by the last cursor")
+	queryLinesPath    = flag.String("query-lines-path", "", "Path to log lines in the query response, e.g. data.logs[*].message")
+	queryCursorPath   = flag.String("query-cursor-path", "", "Path to the next cursor in the query response, e.g. data.next_cursor")
+	insecure          = flag.Bool("insecure", false, "Don't verify SSH host keys, required for sftp, ssh+journal and ssh+exec URLs until host key verification is supported")
+	sshSocket         = flag.String("ssh-socket", "", "Connect to the SSH server through this Unix socket, e.g. a local forward, instead of the address in the URL")
+	sshCiphers        = flag.String("ssh-ciphers", "", "Comma-separated SSH ciphers to offer, in order of preference, e.g. aes128-cbc for legacy servers (default: the library's secure set)")
+	sshKex            = flag.String("ssh-kex", "", "Comma-separated SSH key exchange algorithms to offer, in order of preference")
+	sshMACs           = flag.String("ssh-macs", "", "Comma-separated SSH MAC algorithms to offer, in order of preference")
+	sshHostKeyAlgos   = flag.String("ssh-host-key-algos", "", "Comma-separated SSH host key algorithms to accept, in order of preference, e.g. ssh-ed25519; ssh-rsa for old servers relies on SHA-1 signatures, which can be forged")
+	sshKeepaliveSec   = flag.Int("ssh-keepalive-sec", 0, "Send an SSH keepalive every this many seconds and reconnect when one isn't answered, so firewalls don't drop idle connections (0 disables it)")
+	sftpIdleCloseSec  = flag.Int("sftp-idle-close-sec", 0, "Close the SFTP connection when no new data came for this many seconds and reconnect on the next poll, an alternative to -ssh-keepalive-sec for firewalls dropping idle connections (0 keeps it open)")
+	interactive       = flag.Bool("interactive", false, "Answer SSH keyboard-interactive questions the password doesn't, like one-time codes, on the terminal")
+	sftpProxy         = flag.String("sftp-proxy", "", "Connect to SSH/SFTP servers through a SOCKS5 proxy (socks5://[user:password@]host:port)")
+	waitForFile       = flag.Bool("wait-for-file", false, "Treat a missing HTTP or sftp file as not created yet: poll quietly until it appears, then start at the given start position, e.g. when starting before the application writing the log")
+	followSymlink     = flag.Bool("follow-symlink", false, "With an sftp path that is a symlink, like a \"current\" link repointed on rotation, resolve it on every poll; once it points to another file, the rest of the old one is read and the new one followed from its start")
+	newest            = flag.Bool("newest", false, "With an sftp glob, follow only the most recently modified matching file, switching to a newer one once it appears, e.g. for date-stamped logs")
+	sortWindowSec     = flag.Int("sort-window-sec", 0, "Hold lines for this many seconds and output them ordered by their leading timestamp (see -time-layout), for globs matching several files; lines arriving later than newer ones stay in arrival order")
+	heartbeatSec      = flag.Int("heartbeat-sec", 0, "Print a heartbeat when no lines were printed for this many seconds (0 disables it)")
+	heartbeatMarker   = flag.String("heartbeat-marker", "-- heartbeat --", "Text starting the heartbeat line, followed by the offset and the time of the last successful fetch")
+	heartbeatStdout   = flag.Bool("heartbeat-stdout", false, "Print heartbeats to stdout instead of stderr")
+	controlAddr       = flag.String("control-addr", "", "Serve GET /status and POST /reset on this address, e.g. localhost:8080")
+	quiet             = flag.Bool("quiet", false, "Don't print informational messages, like truncation or missing range support, only errors")
+	showProgress      = flag.Bool("show-progress", false, "Print the offset against the file size, and the time until caught up at the current speed, to stderr every -progress-interval; for sftp and HTTP files, not compressed ones")
+	progressInterval  = flag.Duration("progress-interval", 5*time.Second, "How often -show-progress prints")
+	stats             = flag.Bool("stats", false, "Print a summary of lines, bytes and fetch errors to stderr on exit")
+	decompress        = flag.Bool("decompress", false, "Decompress .gz, .bz2 and .zst files, detected by extension or Content-Type; over HTTP they are fetched whole on every poll, over SFTP only when their size or modification time changed")
+	acceptGzip        = flag.Bool("accept-gzip", false, "Ask HTTP servers to gzip responses, to save bandwidth when they return the whole file; offsets still count uncompressed bytes, servers that gzip range responses cannot be followed this way")
+	chunkBytes        = flag.Int64("chunk-bytes", 0, "Read at most this many new bytes per poll over HTTP and SFTP, catching up with a large backlog over several polls (0 means no limit)")
+	chunkSegments     = flag.Int("chunk-segments", 1, "With -chunk-bytes over HTTP, ask for this many consecutive chunks in one multi-range request, for fewer round trips while catching up; servers without multi-range support get single ranges")
+	rateLimit         = flag.Int("rate-limit", 0, "Limit reading new data to this many bytes per second (0 means no limit)")
+	syslogAddress     = flag.String("syslog", "", "Send lines to syslog instead of stdout: local, udp://host:port or tcp://host:port")
+	syslogFacility    = flag.String("syslog-facility", "user", "Syslog facility, e.g. user, daemon or local0")
+	syslogSeverity    = flag.String("syslog-severity", "info", "Syslog severity, e.g. info, notice or err")
+	syslogTag         = flag.String("syslog-tag", "remote-tail-f", "Syslog tag")
+	userAgent         = flag.String("user-agent", "remote-tail-f/"+version, "User-Agent header sent with HTTP requests")
+	failoverAfter     = flag.Int("failover-after", 3, "Switch to the next URL after this many failed fetches in a row; the URLs must serve byte-identical files")
+	loginURL          = flag.String("login-url", "", "Post the -login-field values as a form to this URL before fetching over HTTP, and again when the session expired, keeping the session cookie in memory")
+	requestIDHeader   = flag.String("request-id-header", "", "Send a unique id with every HTTP request in this header, e.g. X-Request-ID")
+	outputFilePath    = flag.String("output-file", "", "Append lines to this file instead of printing them to stdout; it is reopened on SIGHUP, e.g. after logrotate moved it")
+	outputFifo        = flag.String("output-fifo", "", "Write lines to this named pipe, waiting for a reader to open it; when the reader falls 10000 lines behind and takes nothing for 5s, the oldest lines are dropped rather than blocking (counted as droppedLines in the -control-addr status)")
+	onMatch           = flag.String("on-match", "", "Regular expression that printed lines are checked against, for -on-match-exec and -exit-on-match")
+	onMatchExec       = flag.String("on-match-exec", "", "Shell command run locally for a line matching -on-match, with {} replaced by the quoted line, e.g. \"notify-send {}\"; its output goes to stderr")
+	matchCooldown     = flag.Duration("match-cooldown", 0, "After running -on-match-exec, ignore matches for this long, so a burst of them runs the command once")
+	exitOnMatch       = flag.Bool("exit-on-match", false, "Exit once a line matching -on-match was printed, after the lines fetched with it")
+	webhookURL        = flag.String("webhook", "", "Also POST the lines of every poll to this URL as a JSON array of {source, offset, time, line} objects, source being the file for globs, retrying failures and honoring 429 Retry-After in the background; lines are dropped when 8 polls are waiting, dropped and undeliverable lines count as droppedLines")
+	webhookOnly       = flag.Bool("webhook-only", false, "Send lines only to -webhook, not to stdout or the other outputs")
+	otlpEndpoint      = flag.String("otlp-endpoint", "", "Also export lines as OTLP log records to this collector URL with the OpenTelemetry SDK, over HTTP with protobuf encoding, e.g. http://localhost:4318 (/v1/logs is added to URLs without a path); the time received is the timestamp and the file for globs, or the URL of a single target, the log.source attribute; OTEL_RESOURCE_ATTRIBUTES and OTEL_SERVICE_NAME extend the resource")
+	otlpBatchLines    = flag.Int("otlp-batch-lines", 512, "Export at most this many lines per OTLP request")
+	otlpFlushInterval = flag.Duration("otlp-flush-interval", 5*time.Second, "Export lines to -otlp-endpoint at least this often; when the collector falls 8 batches behind, the oldest queued lines are dropped")
+	queueLines        = flag.Int("queue-lines", 0, "Let fetching run ahead of a slow output by queueing up to this many lines (0 queues one poll)")
+	queueFull         = flag.String("queue-full", "block", "What to do when -queue-lines is reached: block fetching, drop-oldest lines, or spill lines to a temporary file")
+	targetsFile       = flag.String("targets-file", "", "Follow every target listed in this file (- for stdin), one \"URL [FALLBACK_URL...] [-option=value...]\" per line; # starts a comment; re-read on SIGHUP")
+	checkpointSec     = flag.Int("checkpoint-every-sec", 0, "Save state at most once per this many seconds instead of after every fetch; after a crash up to that much output may be printed again (state is always saved on exit)")
+	checkpointMode    = flag.String("checkpoint-mode", "offset", "How to resume after a restart: offset continues at the saved byte offset; time keeps the timestamp of the last line output (see -time-layout) and skips lines up to it, for sources whose offsets can't be relied on, like HTTP servers without range support; lines must start with timestamps that increase, lines sharing the saved timestamp are skipped")
+	checkpointLines   = flag.Int("checkpoint-every-lines", 0, "Save state once at least this many lines were printed since the last save, instead of after every fetch; after a crash up to that many lines may be printed again")
 )
 
-type Tailer interface {
-	FetchNewLines() ([]string, error)
-	LoadState() error
-	SaveState() error
-}
-
-type TailerBase struct {
-	stateFilePath string
-	lastOffset    int64
-}
+// headChunkBytes is how much -head reads per request unless -chunk-bytes is
+// given.
+const headChunkBytes = 64 * 1024
 
-func (t *TailerBase) LoadState() error {
-	if t.stateFilePath == "" {
-		t.lastOffset = 0
-		return nil
-	}
-	data, err := os.ReadFile(t.stateFilePath)
-	if err != nil {
-		if errors.Is(err, os.ErrNotExist) {
-			t.lastOffset = 0
-			return nil
-		}
-		return fmt.Errorf("could not read checkpoint file: %v", err)
-	}
-
-	var lastOffset int64
-	n, err := fmt.Sscanf(string(data), "%d", &lastOffset)
-	if err != nil {
-		return err
+// createTailers follows the first URL, switching to the following ones,
+// mirrors of the same file, when it keeps failing.
+func createTailers(urls []string) (tailer.Tailer, error) {
+	// Checked here as -targets-file lines can set it too.
+	if *requestTimeoutSec <= 0 {
+		return nil, fmt.Errorf("-request-timeout-sec must be positive, requests would time out at once")
 	}
-	if n < 1 {
-		return fmt.Errorf("invalid checkpoint file")
+	if len(urls) == 1 {
+		return createTailer(urls[0])
 	}
-	if lastOffset < 0 {
-		return fmt.Errorf("invalid offset in checkpoint file: %d", lastOffset)
-	}
-	t.lastOffset = lastOffset
-	return nil
-}
-
-func (t *TailerBase) SaveState() error {
-	if t.stateFilePath == "" {
-		return nil
+	var tailers []tailer.Tailer
+	for _, rawURL := range urls {
+		t, err := createTailer(rawURL)
+		if err != nil {
+			for _, created := range tailers {
+				created.Close()
+			}
+			return nil, err
+		}
+		tailers = append(tailers, t)
 	}
-	data := fmt.Sprintf("%d\n", t.lastOffset)
-	return os.WriteFile(t.stateFilePath, []byte(data), 0644)
+	return tailer.NewFailoverTailer(tailers, *failoverAfter), nil
 }
 
-func CreateTailerFromArgs() (Tailer, error) {
-	urlParsed, err := url.Parse(flag.Arg(0))
+func createTailer(rawURL string) (tailer.Tailer, error) {
+	urlParsed, err := url.Parse(rawURL)
 	if err != nil {
 		return nil, fmt.Errorf("invalid url: %v", err)
 	}
 
 	switch urlParsed.Scheme {
 	case "http", "https":
-		return NewHttpTailer(urlParsed.String(), *requestTimeoutSec, *stateFilePath), nil
-	case "sftp":
+		if !httpguts.ValidHeaderFieldValue(*userAgent) {
+			return nil, fmt.Errorf("invalid -user-agent: %q", *userAgent)
+		}
+		if *requestIDHeader != "" && !httpguts.ValidHeaderFieldName(*requestIDHeader) {
+			return nil, fmt.Errorf("invalid -request-id-header: %q", *requestIDHeader)
+		}
+
+		var t interface {
+			tailer.Tailer
+			SetUserAgent(userAgent string)
+			SetRequestIDHeader(name string)
+			SetResolve(hosts map[string]string)
+			SetReadTimeout(d time.Duration)
+			SetHTTPVersion(version string) error
+			SetLogin(loginURL string, fields url.Values) error
+			SetIdleConns(maxIdle int, timeout time.Duration) error
+		}
+		switch {
+		case *stream:
+			if *queryBody != "" || *decompress {
+				return nil, fmt.Errorf("-stream cannot be used with -query-body or -decompress")
+			}
+			if err := checkRewindable("-stream"); err != nil {
+				return nil, err
+			}
+			streamTailer := tailer.NewStreamTailer(urlParsed.String(), *requestTimeoutSec, *stateFilePath)
+			streamTailer.SetIdleTimeout(*streamIdleTimeout)
+			t = streamTailer
+		case *queryBody != "":
+			if *queryLinesPath == "" {
+				return nil, fmt.Errorf("provide the path to log lines in the response through -query-lines-path")
+			}
+			if *decompress {
+				return nil, fmt.Errorf("-decompress cannot be used with -query-body")
+			}
+			if err := checkRewindable("-query-body"); err != nil {
+				return nil, err
+			}
+			t = tailer.NewQueryTailer(urlParsed.String(), *queryBody, *queryLinesPath, *queryCursorPath, *requestTimeoutSec, *stateFilePath)
+		default:
+			httpTailer := tailer.NewHttpTailer(urlParsed.String(), *requestTimeoutSec, *stateFilePath)
+			httpTailer.SetDecompress(*decompress)
+			httpTailer.SetAcceptGzip(*acceptGzip)
+			httpTailer.SetChunkBytes(*chunkBytes)
+			httpTailer.SetChunkSegments(*chunkSegments)
+			httpTailer.SetWaitForFile(*waitForFile)
+			t = httpTailer
+		}
+		t.SetUserAgent(*userAgent)
+		t.SetRequestIDHeader(*requestIDHeader)
+		err = t.SetHTTPVersion(*httpVersion)
+		if err != nil {
+			t.Close()
+			return nil, err
+		}
+		err = t.SetIdleConns(*maxIdleConns, *idleConnTimeout)
+		if err != nil {
+			t.Close()
+			return nil, err
+		}
+		if *readTimeoutSec > 0 {
+			t.SetReadTimeout(time.Duration(*readTimeoutSec) * time.Second)
+		}
+		if len(resolveRules) > 0 {
+			hosts, err := parseResolveRules(resolveRules)
+			if err != nil {
+				t.Close()
+				return nil, err
+			}
+			t.SetResolve(hosts)
+		}
+		if *loginURL != "" {
+			fields := url.Values{}
+			for _, field := range loginFields {
+				name, value, ok := strings.Cut(field, "=")
+				if !ok {
+					t.Close()
+					return nil, fmt.Errorf("invalid -login-field %q, expected NAME=VALUE", field)
+				}
+				fields.Add(name, value)
+			}
+			err = t.SetLogin(*loginURL, fields)
+			if err != nil {
+				t.Close()
+				return nil, err
+			}
+		}
+		return t, nil
+	case "sftp", "ssh+journal", "ssh+exec":
 		password, _ := urlParsed.User.Password()
 		if password == "" {
 			password = os.Getenv("SFTP_PASSWORD")
 		}
-		if password == "" {
-			return nil, fmt.Errorf("provide password in URL or through SFTP_PASSWORD environment variable")
+		if password == "" && !*interactive {
+			return nil, fmt.Errorf("provide password in URL or through SFTP_PASSWORD environment variable, or answer on the terminal with -interactive")
 		}
-		if len(urlParsed.Path) < 1 {
-			return nil, fmt.Errorf("missing file path")
+
+		var t interface {
+			tailer.Tailer
+			SetProxy(proxyURL *url.URL) error
+			SetInsecureIgnoreHostKey()
+			SetUnixSocket(path string)
+			SetResolve(hosts map[string]string)
+			SetPrompt(prompt func(instruction string, question string, echo bool) (string, error))
+			SetAlgorithms(ciphers []string, keyExchanges []string, macs []string) error
+			SetHostKeyAlgorithms(algorithms []string) error
+			SetKeepalive(interval time.Duration)
+		}
+		if urlParsed.Hostname() == "" {
+			return nil, fmt.Errorf("missing host")
+		}
+		address := hostPort(urlParsed, "22")
+
+		if (*decompress || *newest || *followSymlink) && urlParsed.Scheme != "sftp" {
+			return nil, fmt.Errorf("-decompress, -newest and -follow-symlink work only with files")
+		}
+		if urlParsed.Scheme != "sftp" {
+			if err := checkRewindable(urlParsed.Scheme + " URLs"); err != nil {
+				return nil, err
+			}
+		}
+		switch urlParsed.Scheme {
+		case "ssh+journal":
+			t = tailer.NewExecTailer(address, urlParsed.User.Username(), password, *journalCommand, *requestTimeoutSec, *stateFilePath)
+		case "ssh+exec":
+			if *execCommand == "" {
+				return nil, fmt.Errorf("provide the command to run through -exec-command")
+			}
+			t = tailer.NewExecTailer(address, urlParsed.User.Username(), password, *execCommand, *requestTimeoutSec, *stateFilePath)
+		default:
+			if len(urlParsed.Path) < 1 {
+				return nil, fmt.Errorf("missing file path")
+			}
+			relPath := urlParsed.Path[1:]
+			if *newest && !strings.ContainsAny(relPath, "*?[") {
+				return nil, fmt.Errorf("-newest needs a glob matching the files, e.g. /var/log/app-*.log")
+			}
+			if *followSymlink && strings.ContainsAny(relPath, "*?[") {
+				return nil, fmt.Errorf("-follow-symlink cannot be used with a glob")
+			}
+			sftpTailer := tailer.NewSftpTailer(address, urlParsed.User.Username(), password, relPath, *requestTimeoutSec, *stateFilePath)
+			sftpTailer.SetDecompress(*decompress)
+			sftpTailer.SetChunkBytes(*chunkBytes)
+			sftpTailer.SetIdleClose(time.Duration(*sftpIdleCloseSec) * time.Second)
+			sftpTailer.SetNewestOnly(*newest)
+			sftpTailer.SetWaitForFile(*waitForFile)
+			sftpTailer.SetFollowSymlink(*followSymlink)
+			sftpTailer.SetFlushPartialLine(*noFollow)
+			t = sftpTailer
+		}
+
+		if !*insecure {
+			return nil, fmt.Errorf("SSH host keys cannot be verified yet, pass -insecure to connect without verifying them")
+		}
+		t.SetInsecureIgnoreHostKey()
+
+		if *interactive {
+			t.SetPrompt(promptTerminal)
+		}
+		err = t.SetAlgorithms(splitList(*sshCiphers), splitList(*sshKex), splitList(*sshMACs))
+		if err != nil {
+			return nil, err
+		}
+		err = t.SetHostKeyAlgorithms(splitList(*sshHostKeyAlgos))
+		if err != nil {
+			return nil, err
+		}
+		t.SetKeepalive(time.Duration(*sshKeepaliveSec) * time.Second)
+		if *sshSocket != "" {
+			if *sftpProxy != "" {
+				return nil, fmt.Errorf("-ssh-socket and -sftp-proxy are mutually exclusive")
+			}
+			t.SetUnixSocket(*sshSocket)
+		}
+		if *sftpProxy != "" {
+			proxyURL, err := url.Parse(*sftpProxy)
+			if err != nil {
+				return nil, fmt.Errorf("invalid proxy url: %v", err)
+			}
+			err = t.SetProxy(proxyURL)
+			if err != nil {
+				return nil, fmt.Errorf("invalid proxy: %v", err)
+			}
+		}
+		if len(resolveRules) > 0 {
+			hosts, err := parseResolveRules(resolveRules)
+			if err != nil {
+				return nil, err
+			}
+			t.SetResolve(hosts)
+		}
+		return t, nil
+	case "tcp":
+		if urlParsed.Hostname() == "" || urlParsed.Port() == "" {
+			return nil, fmt.Errorf("tcp URLs need a host and a port, e.g. tcp://host:5140")
 		}
-		relPath := urlParsed.Path[1:]
-		return NewSftpTailer(urlParsed.Host, urlParsed.User.Username(), password, relPath, *requestTimeoutSec, *stateFilePath), nil
+		if err := checkRewindable("tcp URLs"); err != nil {
+			return nil, err
+		}
+		address := hostPort(urlParsed, "")
+		if len(resolveRules) > 0 {
+			hosts, err := parseResolveRules(resolveRules)
+			if err != nil {
+				return nil, err
+			}
+			if override, ok := hosts[address]; ok {
+				address = override
+			}
+		}
+		return tailer.NewTcpTailer(address, *requestTimeoutSec, *stateFilePath), nil
+	case "grpc", "grpcs":
+		if err := checkRewindable(urlParsed.Scheme + " URLs"); err != nil {
+			return nil, err
+		}
+		t, err := tailer.NewGrpcTailer(urlParsed.String(), *requestTimeoutSec, *stateFilePath)
+		if err != nil {
+			return nil, err
+		}
+		t.SetUserAgent(*userAgent)
+		if *grpcCACert != "" {
+			err = t.SetCACertFile(*grpcCACert)
+			if err != nil {
+				return nil, err
+			}
+		}
+		return t, nil
 	default:
 		return nil, fmt.Errorf("invalid protocol: %v", urlParsed.Scheme)
 	}
 }
 
+// hostPort returns the host and port of u to dial, with defaultPort where
+// u has none. Hostname drops the brackets of IPv6 literals, JoinHostPort adds
+// them back.
+func hostPort(u *url.URL, defaultPort string) string {
+	port := u.Port()
+	if port == "" {
+		port = defaultPort
+	}
+	return net.JoinHostPort(u.Hostname(), port)
+}
+
+// checkRewindable rejects -max-lines, -max-bytes and -head, which leave the
+// lines over the limit for the next run, for sources that can't return
+// lines again.
+func checkRewindable(source string) error {
+	if *maxLines > 0 || *maxBytes > 0 {
+		return fmt.Errorf("-max-lines, -max-bytes and -head cannot be used with %s, the lines over the limit could not be read again", source)
+	}
+	return nil
+}
+
+// splitList splits a comma-separated flag value, an empty one giving nil.
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// parseResolveRules turns -resolve rules like "example.com:443:10.0.0.1" into
+// a map from "example.com:443" to "10.0.0.1:443".
+func parseResolveRules(rules []string) (map[string]string, error) {
+	hosts := make(map[string]string, len(rules))
+	for _, rule := range rules {
+		host, rest, ok1 := strings.Cut(rule, ":")
+		port, addr, ok2 := strings.Cut(rest, ":")
+		addr = strings.TrimSuffix(strings.TrimPrefix(addr, "["), "]")
+		portNum, err := strconv.Atoi(port)
+		if !ok1 || !ok2 || host == "" || err != nil || portNum < 1 || portNum > 65535 || net.ParseIP(addr) == nil {
+			return nil, fmt.Errorf("invalid -resolve %q, expected HOST:PORT:ADDR with an IP address", rule)
+		}
+		hosts[net.JoinHostPort(host, port)] = net.JoinHostPort(addr, port)
+	}
+	return hosts, nil
+}
+
+// checkpointDue tells whether state should be saved now, given the time and
+// the number of lines printed since it was last saved.
+func checkpointDue(elapsed time.Duration, lines int) bool {
+	if *checkpointSec <= 0 && *checkpointLines <= 0 {
+		return true
+	}
+	return (*checkpointSec > 0 && elapsed >= time.Duration(*checkpointSec)*time.Second) ||
+		(*checkpointLines > 0 && lines >= *checkpointLines)
+}
+
+// startPositionFlags counts the flags overriding the saved position.
+func startPositionFlags() int {
+	set := 0
+	for _, b := range []bool{*reset, *fromOffset >= 0, *fromEnd, *lastLines >= 0, *since != ""} {
+		if b {
+			set++
+		}
+	}
+	return set
+}
+
+func applyStartPosition(ctx context.Context, t tailer.Tailer) error {
+	if startPositionFlags() > 1 {
+		return fmt.Errorf("-reset, -from-offset, -from-end, -lines and -since are mutually exclusive")
+	}
+
+	switch {
+	case *reset:
+		return t.SetPosition(ctx, 0, io.SeekStart)
+	case *fromOffset >= 0:
+		return t.SetPosition(ctx, *fromOffset, io.SeekStart)
+	case *fromEnd:
+		return t.SetPosition(ctx, 0, io.SeekEnd)
+	case *lastLines >= 0:
+		return t.SetPositionLastLines(ctx, *lastLines)
+	case *since != "":
+		sinceTime, err := time.Parse(*timeLayout, *since)
+		if err != nil {
+			return fmt.Errorf("invalid -since: %v", err)
+		}
+		return t.SetPositionAtTime(ctx, sinceTime, *timeLayout)
+	}
+	return nil
+}
+
+var redactRules, resolveRules, loginFields stringList
+
+// errorPolicy is the parsed -retry-policy.
+var errorPolicy retryPolicy
+
 func main() {
+	flag.Var(&redactRules, "redact", "Replace matches of a regular expression in printed lines, given as PATTERN=REPLACEMENT (write = in the pattern as \\=); can be repeated")
+	flag.Var(&resolveRules, "resolve", "Connect to ADDR whenever HOST:PORT is requested, given as HOST:PORT:ADDR; can be repeated")
+	flag.Var(&loginFields, "login-field", "Form field posted to -login-url, given as NAME=VALUE, e.g. username=admin; can be repeated")
 	flag.Parse()
-	if flag.NArg() != 1 {
-		fmt.Fprintf(os.Stderr, "Usage: %s [OPTIONS] URL\n\nOptions:\n", os.Args[0])
+	err := loadFlagDefaults(*configPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+	if flag.NArg() < 1 && *targetsFile == "" {
+		fmt.Fprintf(os.Stderr, "Usage: %s [OPTIONS] URL [FALLBACK_URL...]\n       %s [OPTIONS] -targets-file FILE\n\nOptions:\n", os.Args[0], os.Args[0])
 		flag.PrintDefaults()
+		fmt.Fprintf(os.Stderr, "\nExit codes of -once runs: 1 error, %d connection failed, %d authentication failed, %d file not found, %d other HTTP 4xx, %d HTTP 5xx.\n",
+			exitConnect, exitAuth, exitNotFound, exitHTTPClient, exitHTTPServer)
 		os.Exit(1)
 	}
+	os.Exit(run())
+}
+
+// run follows the file until interrupted. It is split from main so deferred
+// cleanup, like closing the SFTP connection, happens before the process exits.
+func run() int {
+	trim, err := newTrimmer(*trimMode)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return 1
+	}
 
-	tailer, err := CreateTailerFromArgs()
+	errorPolicy, err = parseRetryPolicy(*retryPolicyRules)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid -retry-policy: %v\n", err)
+		return 1
+	}
+
+	decode, err := newDecoder(*inputEncoding)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return 1
+	}
+
+	binary, err := newBinaryFilter(*binaryMode)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return 1
+	}
+
+	switch tailer.TruncatePolicy(*onTruncate) {
+	case tailer.TruncateReset, tailer.TruncateSkip, tailer.TruncateError:
+	default:
+		fmt.Fprintf(os.Stderr, "invalid -on-truncate %q, must be reset, skip or error\n", *onTruncate)
+		return 1
+	}
+
+	if *defaultStart != "beginning" && *defaultStart != "end" {
+		fmt.Fprintf(os.Stderr, "invalid -default-start %q, must be beginning or end\n", *defaultStart)
+		return 1
+	}
+
+	if *checkpointMode != "offset" && *checkpointMode != "time" {
+		fmt.Fprintf(os.Stderr, "invalid -checkpoint-mode %q, must be offset or time\n", *checkpointMode)
+		return 1
+	}
+
+	// A request slower than the interval only delays the next poll, so the two
+	// are not checked against each other.
+	if *intervalSec <= 0 {
+		fmt.Fprintf(os.Stderr, "-interval-sec must be positive, polling without a pause would spin\n")
+		return 1
+	}
+
+	redact, err := newRedactor(redactRules)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return 1
+	}
+	var trigger *matchTrigger
+	if *onMatch != "" {
+		trigger, err = newMatchTrigger(*onMatch, *onMatchExec, *matchCooldown)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			return 1
+		}
+		defer trigger.Wait()
+	} else if *onMatchExec != "" || *exitOnMatch {
+		fmt.Fprintf(os.Stderr, "-on-match-exec and -exit-on-match require -on-match\n")
+		return 1
+	}
+
+	if *head > 0 {
+		if *maxLines > 0 {
+			fmt.Fprintf(os.Stderr, "-head and -max-lines are mutually exclusive\n")
+			return 1
+		}
+		*once = true
+		*maxLines = *head
+		if *chunkBytes == 0 {
+			*chunkBytes = headChunkBytes
+		}
+	}
+
+	if *peek {
+		*once = true
+	}
+
+	if *noFollow {
+		if startPositionFlags() > 0 {
+			fmt.Fprintf(os.Stderr, "-no-follow always prints the whole file, it cannot be used with a start position\n")
+			return 1
+		}
+		*stateFilePath, *stateDir = "", ""
+		*once = true
+	}
+
+	if *reverse && !*once {
+		fmt.Fprintf(os.Stderr, "-reverse cannot be used while following, add -once, -no-follow, -head or -peek\n")
+		return 1
+	}
+
+	status := &runStatus{offsets: map[string]int64{}, events: map[string]int64{}, errorsByCategory: map[string]int64{}}
+	var output *outputFile
+	var stdout io.Writer = os.Stdout
+	if *outputFilePath != "" {
+		if *syslogAddress != "" {
+			fmt.Fprintf(os.Stderr, "-output-file and -syslog are mutually exclusive\n")
+			return 1
+		}
+		output, err = openOutputFile(*outputFilePath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			return 1
+		}
+		defer output.Close()
+		stdout = output
+	}
+	if *outputFifo != "" {
+		if *outputFilePath != "" || *syslogAddress != "" {
+			fmt.Fprintf(os.Stderr, "-output-fifo cannot be used with -output-file or -syslog\n")
+			return 1
+		}
+		fifo, err := openFifoOutput(*outputFifo, status)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			return 1
+		}
+		defer fifo.Close()
+		stdout = fifo
+	}
+	out := bufio.NewWriter(stdout)
+	defer out.Flush()
+
+	var sink io.Writer = out
+	if *syslogAddress != "" {
+		w, err := dialSyslog(*syslogAddress, *syslogFacility, *syslogSeverity, *syslogTag)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			return 1
+		}
+		defer w.Close()
+		sink = w
+	}
+
+	var webhook *webhookOutput
+	if *webhookURL != "" {
+		webhook = newWebhookOutput(*webhookURL, time.Duration(*requestTimeoutSec)*time.Second, status)
+		defer webhook.close()
+	} else if *webhookOnly {
+		fmt.Fprintf(os.Stderr, "-webhook-only requires -webhook\n")
+		return 1
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if *stateDir != "" && *stateFilePath != "" {
+		fmt.Fprintf(os.Stderr, "-state-file and -state-dir are mutually exclusive\n")
+		return 1
+	}
+	targets := []target{{key: strings.Join(flag.Args(), " "), urls: flag.Args()}}
+	if *targetsFile != "" {
+		if flag.NArg() > 0 || *stateFilePath != "" {
+			fmt.Fprintf(os.Stderr, "-targets-file cannot be used with URL arguments or -state-file, use -state-dir to keep state\n")
+			return 1
+		}
+		targets, err = readTargets(*targetsFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			return 1
+		}
+	}
+	var otlp *otlpOutput
+	if *otlpEndpoint != "" {
+		defaultSource := ""
+		if len(targets) == 1 && len(targets[0].urls) > 0 {
+			if parsed, err := url.Parse(targets[0].urls[0]); err == nil {
+				defaultSource = parsed.Redacted()
+			}
+		}
+		otlp, err = newOtlpOutput(*otlpEndpoint, *otlpBatchLines, *otlpFlushInterval, time.Duration(*requestTimeoutSec)*time.Second, defaultSource, status)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			return 1
+		}
+		defer otlp.close()
+	}
+	warnInsecure(targets)
+	var tailers []tailer.Tailer
+	for _, tg := range targets {
+		t, exitCode := startTarget(ctx, tg, status)
+		if exitCode != 0 {
+			for _, started := range tailers {
+				started.Close()
+			}
+			return exitCode
+		}
+		tailers = append(tailers, t)
+	}
+
+	// -duration and -exit-on-match stop following by cancelling ctx.
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	if *duration > 0 {
+		go func() {
+			select {
+			case <-clk.After(*duration):
+				cancel()
+			case <-ctx.Done():
+			}
+		}()
+	}
+
+	// Fetching runs ahead of printing, so a slow output doesn't delay the next
+	// poll. State is saved only after the lines before it were written out.
+	batches := make(chan batch, 1)
+	fetched := batches
+	if *queueLines > 0 {
+		queue, err := newBatchQueue(*queueLines, *queueFull, status)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			for _, t := range tailers {
+				t.Close()
+			}
+			return 1
+		}
+		fetched = make(chan batch)
+		go queue.relay(fetched, batches)
+	}
+	start := clk.Now()
+	if *controlAddr != "" {
+		listener, err := net.Listen("tcp", *controlAddr)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to start control endpoint: %v\n", err)
+			for _, t := range tailers {
+				t.Close()
+			}
+			return 1
+		}
+		server := &http.Server{Handler: newControlHandler(status)}
+		go server.Serve(listener)
+		defer server.Close()
+	}
+	fl := newFleet(ctx, *targetsFile, fetched, status)
+	for i, t := range tailers {
+		fl.start(targets[i], t)
+	}
+	var reload chan struct{}
+	if *targetsFile != "" {
+		reload = make(chan struct{}, 1)
+	}
+	go fl.run(reload)
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+	defer signal.Stop(hangup)
+
+	var joiner *multilineJoiner
+	if *multiline != "" {
+		start, err := regexp.Compile(*multiline)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "invalid -multiline: %v\n", err)
+			return 1
+		}
+		joiner = newMultilineJoiner(start, *multilineSep)
+	}
+	var sorter *sortBuffer
+	if *sortWindowSec > 0 {
+		sorter = newSortBuffer(time.Duration(*sortWindowSec)*time.Second, *timeLayout)
+	}
+	// write outputs lines and then saves the state after them, if any, unless
+	// the last checkpoint is too recent. -head and -peek only look at the file.
+	persist := *head == 0 && !*peek
+	lastCheckpoint := clk.Now()
+	linesSinceCheckpoint := 0
+	var dedup *lineDeduplicator
+	if *dedupWindow > 0 {
+		dedup = newLineDeduplicator(*dedupWindow)
+	}
+	write := func(lines []tailer.Line, states []tailer.StateSnapshot, heartbeat string) {
+		written := 0
+		var posted []webhookLine
+		var exported []otlpRecord
+		now := clk.Now()
+		for _, line := range lines {
+			if dedup != nil && dedup.repeated(line.Text) {
+				continue
+			}
+			line.Text = redact(line.Text)
+			if !*webhookOnly {
+				fmt.Fprintln(sink, formatLine(line))
+			}
+			if trigger != nil && trigger.check(line.Text) && *exitOnMatch {
+				cancel()
+			}
+			if webhook != nil {
+				posted = append(posted, webhookLine{Source: line.Source, Offset: line.Offset, Time: now, Line: line.Text})
+			}
+			if otlp != nil {
+				exported = append(exported, otlpRecord{source: line.Source, time: now, line: line.Text})
+			}
+			written++
+		}
+		if webhook != nil {
+			webhook.add(posted)
+		}
+		if otlp != nil {
+			otlp.add(exported)
+		}
+		status.mu.Lock()
+		status.linesEmitted += int64(written)
+		status.mu.Unlock()
+		if heartbeat != "" {
+			if *heartbeatStdout {
+				fmt.Fprintln(out, heartbeat)
+			} else {
+				fmt.Fprintln(os.Stderr, heartbeat)
+			}
+		}
+		err := out.Flush()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to write output: %v\n", err)
+			return
+		}
+		linesSinceCheckpoint += len(lines)
+		if len(states) > 0 && persist && checkpointDue(clk.Now().Sub(lastCheckpoint), linesSinceCheckpoint) {
+			lastCheckpoint = clk.Now()
+			linesSinceCheckpoint = 0
+			for _, state := range states {
+				err = state.Save()
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "Failed to save state: %v\n", err)
+				}
+			}
+		}
+	}
+	// -reverse holds the lines until the end, only heartbeats go out at once.
+	var reversed []tailer.Line
+	var reversedStates []tailer.StateSnapshot
+	emit := write
+	if *reverse {
+		write = func(lines []tailer.Line, states []tailer.StateSnapshot, heartbeat string) {
+			reversed = append(reversed, lines...)
+			reversedStates = append(reversedStates, states...)
+			if heartbeat != "" {
+				emit(nil, nil, heartbeat)
+			}
+		}
+	}
+
+output:
+	for {
+		select {
+		case b, ok := <-batches:
+			if !ok {
+				break output
+			}
+			for i := range b.lines {
+				b.lines[i].Text = trim(decode(b.lines[i].Text))
+			}
+			if *skipEmpty {
+				b.lines = dropEmpty(b.lines)
+			}
+			lines, state := binary.filter(b.lines), &b.state
+			if joiner != nil {
+				lines, state = joiner.add(b.source, lines, b.state)
+			}
+			var states []tailer.StateSnapshot
+			if sorter != nil {
+				var held tailer.StateSnapshot // nothing to save when state is nil
+				if state != nil {
+					held = *state
+				}
+				sorter.add(b.source, lines, held, clk.Now())
+				lines, states = sorter.release(clk.Now(), false)
+			} else if state != nil {
+				states = []tailer.StateSnapshot{*state}
+			}
+			write(lines, states, b.heartbeat)
+		case <-hangup:
+			// SIGHUP reopens the output file and re-reads the targets file.
+			if output != nil {
+				out.Flush()
+				err := output.Reopen()
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "Failed to reopen output: %v\n", err)
+				}
+			}
+			if reload != nil {
+				select {
+				case reload <- struct{}{}:
+				default: // a reload is already pending
+				}
+			}
+		}
+	}
+	if joiner != nil {
+		if sorter != nil {
+			sorter.add(nil, joiner.flush(), tailer.StateSnapshot{}, clk.Now())
+		} else {
+			write(joiner.flush(), nil, "")
+		}
+	}
+	if sorter != nil {
+		lines, states := sorter.release(clk.Now(), true)
+		write(lines, states, "")
+	}
+	if *reverse {
+		slices.Reverse(reversed)
+		emit(reversed, reversedStates, "")
+	}
+
+	// The fetch loops have all ended once batches is closed.
+	var offset int64
+	for _, t := range fl.ended {
+		if persist {
+			err = t.SaveState()
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Failed to save state: %v\n", err)
+			}
+		}
+		offset += t.Offset()
+		t.Close()
+	}
+	if *stats {
+		fmt.Fprintf(os.Stderr, "Lines emitted: %d, bytes read: %d, fetch errors: %d, elapsed: %v, offset: %d\n",
+			status.linesEmitted, status.bytesRead, status.fetchErrors, clk.Now().Sub(start).Round(time.Millisecond), offset)
+	}
+	return fl.exitCode
+}
+
+// insecureWarning makes warnInsecure print once per run, however many
+// targets connect without verifying host keys and however often they are
+// reloaded.
+var insecureWarning sync.Once
+
+// warnInsecure warns that host keys are not verified if one of targets
+// connects over SSH with -insecure.
+func warnInsecure(targets []target) {
+	for _, tg := range targets {
+		skip := *insecure
+		if value, ok := tg.options["insecure"]; ok {
+			skip, _ = strconv.ParseBool(value)
+		}
+		if !skip {
+			continue
+		}
+		for _, rawURL := range tg.urls {
+			u, err := url.Parse(rawURL)
+			if err == nil && (u.Scheme == "sftp" || u.Scheme == "ssh+journal" || u.Scheme == "ssh+exec") {
+				insecureWarning.Do(func() {
+					fmt.Fprintf(os.Stderr, "Warning: SSH host key verification is disabled, the connection is open to man-in-the-middle attacks.\n")
+				})
+				return
+			}
+		}
+	}
+}
+
+// startTarget creates the tailer for tg and moves it to where following
+// starts. On failure it prints why and returns a non-zero exit code.
+func startTarget(ctx context.Context, tg target, status *runStatus) (tailer.Tailer, int) {
+	t, err := createTargetTailer(tg)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Failed to create Tailer: %v\n", err)
-		os.Exit(1)
+		return nil, 1
+	}
+	t.SetRateLimit(*rateLimit)
+	t.SetTruncatePolicy(tailer.TruncatePolicy(*onTruncate))
+	t.SetEventHandler(func(e tailer.Event) {
+		status.countEvent(e.Kind)
+		if !*quiet && e.Message != "" {
+			fmt.Fprintln(os.Stderr, e.Message)
+		}
+	})
+	if *stateDir != "" {
+		err = t.SetStateDir(*stateDir)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			t.Close()
+			return nil, 1
+		}
 	}
-	err = tailer.LoadState()
+	found, err := t.LoadState()
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Failed to load state: %v\n", err)
 	}
+	// In time mode the saved offset is not used, a start position given
+	// outputs its lines again.
+	byTime := *checkpointMode == "time"
+	if byTime && startPositionFlags() > 0 {
+		t.SetCheckpointTime(time.Time{})
+	}
+	resume := byTime && !t.CheckpointTime().IsZero()
+	if startPositionFlags() == 0 && !resume {
+		err = t.CheckPosition(ctx)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to check saved position: %v\n", err)
+		}
+	}
+	err = retry(ctx, func() error {
+		if resume {
+			return resumeAtTime(ctx, t)
+		}
+		if !found && startPositionFlags() == 0 && *defaultStart == "end" && !*noFollow {
+			return t.SetPosition(ctx, 0, io.SeekEnd)
+		}
+		return applyStartPosition(ctx, t)
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to set start position: %v\n", err)
+		t.Close()
+		return nil, exitCodeFor(err)
+	}
+	return t, 0
+}
+
+// batch is what one poll hands over to the output.
+type batch struct {
+	source    tailer.Tailer
+	lines     []tailer.Line
+	state     tailer.StateSnapshot // state after lines
+	heartbeat string
+}
 
+// fetchLoop polls t, following the target named name, and sends the new
+// lines to batches until ctx is done or a limit is reached. It returns the
+// exit code.
+func fetchLoop(ctx context.Context, name string, t tailer.Tailer, batches chan<- batch, status *runStatus) int {
+	exitCode := 0
+	var emittedLines, emittedBytes int64
+	lastOutput := clk.Now()
+	var lastSuccess time.Time
+	resetsSeen := status.resetCount()
+	var progress progressReporter
+	var resume *timeResume
+	if *checkpointMode == "time" {
+		resume = newTimeResume(t.CheckpointTime())
+	}
+	status.mu.Lock()
+	status.offsets[name] = t.Offset()
+	status.mu.Unlock()
 	for {
-		lines, err := tailer.FetchNewLines()
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error fetching file: %v\n", err)
+		if status.takeReset(&resetsSeen) {
+			err := t.SetPosition(ctx, 0, io.SeekStart)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Failed to reset: %v\n", err)
+			}
+		}
+		var lines []tailer.Line
+		var err error
+		retry(ctx, func() error {
+			lines, err = t.FetchNewLines(ctx)
+			if len(lines) > 0 {
+				return nil // output what was read before retrying
+			}
+			return err
+		})
+		if ctx.Err() != nil {
+			if len(lines) == 0 {
+				break
+			}
+			// The position is already past the lines read before stopping,
+			// they go out before the state is saved on exit.
+			err = nil
+		}
+		var fetchedBytes int64
+		for _, line := range lines {
+			fetchedBytes += int64(len(line.Text)) + 1
+		}
+		status.mu.Lock()
+		if err == nil {
+			lastSuccess = clk.Now()
+			status.lastSuccess = lastSuccess
+			status.lastError = ""
 		} else {
-			err := tailer.SaveState()
+			status.lastError = err.Error()
+			status.fetchErrors++
+			status.errorsByCategory[errorCategory(err)]++
+		}
+		status.mu.Unlock()
+		truncated := errors.Is(err, tailer.ErrTruncated)
+		interval := time.Duration(*intervalSec) * time.Second
+		giveUp := false
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error fetching file (%s): %v\n", errorCategory(err), err)
+			var retryable bool
+			interval, retryable = errorPolicy.delay(err, interval)
+			giveUp = !retryable
+			if *once || truncated || giveUp {
+				exitCode = exitCodeFor(err)
+			}
+		}
+		b := batch{source: t}
+		limitReached := false
+		if err == nil || len(lines) > 0 {
+			if resume != nil {
+				lines = resume.filter(lines)
+			}
+			if *maxLines > 0 || *maxBytes > 0 {
+				keep := 0
+				for _, line := range lines {
+					lineBytes := int64(len(line.Text)) + 1
+					if *maxLines > 0 && emittedLines >= *maxLines || *maxBytes > 0 && emittedBytes+lineBytes > *maxBytes {
+						limitReached = true
+						break
+					}
+					emittedLines++
+					emittedBytes += lineBytes
+					keep++
+				}
+				// Leave the lines over the limit for the next run. Should that
+				// fail, go back to the saved position rather than save one past
+				// them.
+				err := t.Rewind(lines[keep:])
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "Failed to keep position at the limit: %v\n", err)
+					exitCode = 1
+					if _, err := t.LoadState(); err != nil {
+						fmt.Fprintf(os.Stderr, "Failed to load state: %v\n", err)
+					}
+				}
+				lines = lines[:keep]
+			}
+
+			status.mu.Lock()
+			for _, line := range lines {
+				status.bytesRead += int64(len(line.Text)) + 1
+			}
+			status.offsets[name] = t.Offset()
+			status.mu.Unlock()
+			b.lines = lines
+			if resume != nil {
+				if ts, ok := lastLineTime(lines); ok {
+					t.SetCheckpointTime(ts)
+				}
+			}
+			b.state, err = t.SnapshotState()
 			if err != nil {
 				fmt.Fprintf(os.Stderr, "Failed to save state: %v\n", err)
 			}
-			for _, line := range lines {
-				fmt.Println(line)
+			if len(lines) > 0 {
+				lastOutput = clk.Now()
+			}
+		}
+		if *showProgress {
+			progress.report(t)
+		}
+		if *heartbeatSec > 0 && clk.Now().Sub(lastOutput) >= time.Duration(*heartbeatSec)*time.Second {
+			b.heartbeat = heartbeatLine(t.Offset(), lastSuccess)
+			lastOutput = clk.Now()
+		}
+		batches <- b
+		if limitReached || truncated || giveUp || ctx.Err() != nil {
+			break
+		}
+		if *once {
+			// -head reads chunk after chunk until it has enough lines,
+			// -no-follow until the end of the file.
+			if (*head > 0 || *noFollow) && len(lines) > 0 {
+				continue
 			}
+			break
+		}
+		if *catchupMinBytes > 0 && fetchedBytes >= *catchupMinBytes {
+			interval = *catchupInterval // still behind
+		}
+		if !sleep(ctx, interval) {
+			break
+		}
+	}
+	return exitCode
+}
+
+// retry calls f until it succeeds, up to -retries more times when running
+// once. Without -once the polling loop itself retries. While the tailer puts
+// off connecting after failures, it waits for that, calls failing because of
+// it don't count.
+func retry(ctx context.Context, f func() error) error {
+	attempts := 0
+	if *once {
+		attempts = *retries
+	}
+	for {
+		err := f()
+		if err == nil || attempts == 0 || ctx.Err() != nil {
+			return err
+		}
+		if _, ok := errorPolicy.delay(err, 0); !ok {
+			return err // the policy says retrying is pointless
 		}
-		time.Sleep(time.Duration(*intervalSec) * time.Second)
+		delay := *retryDelay
+		var backoff *tailer.BackoffError
+		if errors.As(err, &backoff) {
+			delay = max(delay, time.Until(backoff.Until)) // no attempt was made
+		} else {
+			attempts--
+		}
+		fmt.Fprintf(os.Stderr, "%v, retrying in %v\n", err, delay.Round(time.Millisecond))
+		if !sleep(ctx, delay) {
+			return err
+		}
+	}
+}
+
+// heartbeatLine shows that the tailer is alive while the log is quiet.
+func heartbeatLine(offset int64, lastSuccess time.Time) string {
+	success := "never"
+	if !lastSuccess.IsZero() {
+		success = lastSuccess.Format(time.RFC3339)
+	}
+	return fmt.Sprintf("%s offset=%d last-success=%s", *heartbeatMarker, offset, success)
+}
+
+// Exit codes telling failures apart, for scripts.
+const (
+	exitConnect    = 3
+	exitAuth       = 4
+	exitNotFound   = 5
+	exitHTTPClient = 6
+	exitHTTPServer = 7
+)
+
+func exitCodeFor(err error) int {
+	var statusErr *tailer.StatusError
+	switch {
+	case errors.Is(err, tailer.ErrAuth):
+		return exitAuth
+	case errors.Is(err, os.ErrNotExist):
+		return exitNotFound
+	case errors.Is(err, tailer.ErrConnect):
+		return exitConnect
+	case errors.As(err, &statusErr) && statusErr.StatusCode >= 500:
+		return exitHTTPServer
+	case errors.As(err, &statusErr) && statusErr.StatusCode >= 400:
+		return exitHTTPClient
+	default:
+		return 1
+	}
+}
+
+// sleep waits for d and reports false if ctx was done first.
+func sleep(ctx context.Context, d time.Duration) bool {
+	select {
+	case <-ctx.Done():
+		return false
+	case <-clk.After(d):
+		return true
 	}
 }
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestHostPort(t *testing.T) {
+	tests := []struct {
+		url  string
+		want string
+	}{
+		{url: "sftp://host/var/log/app.log", want: "host:22"},
+		{url: "sftp://192.0.2.1/var/log/app.log", want: "192.0.2.1:22"},
+		{url: "sftp://192.0.2.1:2222/var/log/app.log", want: "192.0.2.1:2222"},
+		{url: "sftp://[::1]/var/log/app.log", want: "[::1]:22"},
+		{url: "sftp://user@[::1]:22/var/log/app.log", want: "[::1]:22"},
+		{url: "sftp://[2001:db8::1]:2222/var/log/app.log", want: "[2001:db8::1]:2222"},
+		{url: "tcp://[2001:db8::1]:5140", want: "[2001:db8::1]:5140"},
+	}
+	for _, test := range tests {
+		u, err := url.Parse(test.url)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := hostPort(u, "22"); got != test.want {
+			t.Errorf("%s: got %s, want %s", test.url, got, test.want)
+		}
+	}
+}
+
+func TestHttpTailerReachesIPv6Host(t *testing.T) {
+	ln, err := net.Listen("tcp", "[::1]:0")
+	if err != nil {
+		t.Skipf("no IPv6 loopback: %v", err)
+	}
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("one\n"))
+	}))
+	server.Listener = ln
+	server.Start()
+	defer server.Close()
+
+	// The server URL is like http://[::1]:port.
+	tl, err := createTailer(server.URL + "/app.log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tl.Close()
+	lines, err := tl.FetchNewLines(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(lines) != 1 || lines[0].Text != "one" {
+		t.Errorf("got %+v", lines)
+	}
+}
+
+func TestFlagsParse(t *testing.T) {
+	// Registering a flag twice panics when the package is initialized, so
+	// getting here already shows each is defined once.
+	args := []string{"-interval-sec", "5", "-request-timeout-sec", "3", "-state-file", "app.state"}
+	t.Cleanup(func() {
+		for _, name := range []string{"interval-sec", "request-timeout-sec", "state-file"} {
+			flag.Set(name, flag.Lookup(name).DefValue)
+		}
+	})
+	if err := flag.CommandLine.Parse(args); err != nil {
+		t.Fatal(err)
+	}
+	if *intervalSec != 5 || *requestTimeoutSec != 3 || *stateFilePath != "app.state" {
+		t.Errorf("got -interval-sec %d, -request-timeout-sec %d, -state-file %q", *intervalSec, *requestTimeoutSec, *stateFilePath)
+	}
+}
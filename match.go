@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prokoma/remote-tail-f/tailer"
+)
+
+// matchTrigger runs a command for printed lines matching -on-match. After a
+// run, matches are ignored for the cooldown so a burst of them runs it once.
+type matchTrigger struct {
+	re       *regexp.Regexp
+	command  string
+	cooldown time.Duration
+	lastRun  time.Time
+	running  sync.WaitGroup
+}
+
+func newMatchTrigger(pattern string, command string, cooldown time.Duration) (*matchTrigger, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid -on-match: %v", err)
+	}
+	return &matchTrigger{re: re, command: command, cooldown: cooldown}, nil
+}
+
+// check tells whether line matches, and starts the command if it is due.
+func (m *matchTrigger) check(line string) bool {
+	if !m.re.MatchString(line) {
+		return false
+	}
+	if m.command == "" || !m.lastRun.IsZero() && clk.Now().Sub(m.lastRun) < m.cooldown {
+		return true
+	}
+	m.lastRun = clk.Now()
+	// The command's output goes to stderr to keep stdout for the lines.
+	cmd := exec.Command("sh", "-c", strings.ReplaceAll(m.command, "{}", tailer.QuoteShell(line)))
+	cmd.Stdout = os.Stderr
+	cmd.Stderr = os.Stderr
+	err := cmd.Start()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to run -on-match-exec command: %v\n", err)
+		return true
+	}
+	m.running.Add(1)
+	go func() {
+		defer m.running.Done()
+		err := cmd.Wait()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "-on-match-exec command failed: %v\n", err)
+		}
+	}()
+	return true
+}
+
+// Wait waits for the commands still running.
+func (m *matchTrigger) Wait() {
+	m.running.Wait()
+}
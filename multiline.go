@@ -0,0 +1,85 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/prokoma/remote-tail-f/tailer"
+)
+
+// multilineJoiner joins lines into records, such as a stack trace with the
+// log line before it. A record starts with a line matching start and takes
+// the following lines up to the next such line. The last record of a source
+// is held until a later poll shows whether it continues, or the source had
+// nothing new. A record keeps the Source of its lines.
+type multilineJoiner struct {
+	start     *regexp.Regexp
+	separator string
+	sources   map[tailer.Tailer]*multilineSource
+}
+
+type multilineSource struct {
+	record    []tailer.Line
+	lastState *tailer.StateSnapshot // state after the previous batch
+	safeState *tailer.StateSnapshot // state before the held record
+}
+
+func newMultilineJoiner(start *regexp.Regexp, separator string) *multilineJoiner {
+	return &multilineJoiner{start: start, separator: separator, sources: map[tailer.Tailer]*multilineSource{}}
+}
+
+// add returns the records completed by lines from source and the state to
+// save after writing them. The state never goes past the held record, so it
+// is read again after a restart. A record has the offset of its first line.
+func (j *multilineJoiner) add(source tailer.Tailer, lines []tailer.Line, state tailer.StateSnapshot) ([]tailer.Line, *tailer.StateSnapshot) {
+	s := j.sources[source]
+	if s == nil {
+		s = &multilineSource{}
+		j.sources[source] = s
+	}
+
+	var records []tailer.Line
+	if len(lines) == 0 && s.record != nil {
+		records = append(records, j.join(s.record))
+		s.record = nil
+	}
+	for _, line := range lines {
+		// Lines of another file, from a tailer following several, start a
+		// record of their own.
+		if s.record != nil && line.Source == s.record[0].Source && !j.start.MatchString(line.Text) {
+			s.record = append(s.record, line)
+			continue
+		}
+		if s.record != nil {
+			records = append(records, j.join(s.record))
+		}
+		s.record = []tailer.Line{line}
+		s.safeState = s.lastState
+	}
+	s.lastState = &state
+
+	if s.record != nil {
+		return records, s.safeState
+	}
+	return records, &state
+}
+
+func (j *multilineJoiner) join(record []tailer.Line) tailer.Line {
+	texts := make([]string, len(record))
+	for i, line := range record {
+		texts[i] = line.Text
+	}
+	return tailer.Line{Text: strings.Join(texts, j.separator), Offset: record[0].Offset, Source: record[0].Source}
+}
+
+// flush returns the records still held.
+func (j *multilineJoiner) flush() []tailer.Line {
+	var records []tailer.Line
+	for _, s := range j.sources {
+		if s.record != nil {
+			records = append(records, j.join(s.record))
+			s.record = nil
+		}
+	}
+	return records
+}
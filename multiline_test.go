@@ -0,0 +1,34 @@
+package main
+
+import (
+	"regexp"
+	"slices"
+	"testing"
+
+	"github.com/prokoma/remote-tail-f/tailer"
+)
+
+func TestMultilineKeepsSource(t *testing.T) {
+	joiner := newMultilineJoiner(regexp.MustCompile(`^\d`), "\n")
+	source := tailer.NewSftpTailer("host:22", "user", "", "/logs/*.log", 1, "")
+	lines := []tailer.Line{
+		{Text: "1 error", Offset: 0, Source: "/logs/a.log"},
+		{Text: "  at main", Offset: 8, Source: "/logs/a.log"},
+		{Text: "  at caller", Offset: 18, Source: "/logs/a.log"},
+		{Text: "2 done", Offset: 0, Source: "/logs/b.log"},
+		{Text: "  more", Offset: 7, Source: "/logs/b.log"},
+		// Doesn't continue the record of b.log.
+		{Text: "  at other", Offset: 30, Source: "/logs/a.log"},
+	}
+
+	records, _ := joiner.add(source, lines, tailer.StateSnapshot{})
+	records = append(records, joiner.flush()...)
+	want := []tailer.Line{
+		{Text: "1 error\n  at main\n  at caller", Offset: 0, Source: "/logs/a.log"},
+		{Text: "2 done\n  more", Offset: 0, Source: "/logs/b.log"},
+		{Text: "  at other", Offset: 30, Source: "/logs/a.log"},
+	}
+	if !slices.Equal(records, want) {
+		t.Errorf("got %+v, want %+v", records, want)
+	}
+}
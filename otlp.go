@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlplog/otlploghttp"
+	"go.opentelemetry.io/otel/log"
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+	"go.opentelemetry.io/otel/sdk/resource"
+)
+
+const otlpQueueBatches = 8 // batches the queue holds before lines are dropped
+
+// otlpRecord is a line waiting to be exported.
+type otlpRecord struct {
+	source string
+	time   time.Time
+	line   string
+}
+
+// otlpOutput exports lines as OTLP log records to a collector with the
+// OpenTelemetry SDK, over HTTP with protobuf encoding. Its batch processor
+// exports in the background, so a slow collector doesn't hold up the output;
+// when the queue is full, the oldest lines are dropped instead.
+type otlpOutput struct {
+	provider      *sdklog.LoggerProvider
+	logger        log.Logger
+	defaultSource string // for lines without a source of their own
+}
+
+// newOtlpOutput starts exporting to endpoint. An endpoint without a path
+// gets the default /v1/logs. The resource can be extended through
+// OTEL_RESOURCE_ATTRIBUTES and OTEL_SERVICE_NAME.
+func newOtlpOutput(endpoint string, batchLines int, flushInterval time.Duration, timeout time.Duration, defaultSource string, status *runStatus) (*otlpOutput, error) {
+	parsed, err := url.Parse(endpoint)
+	if err != nil || parsed.Scheme != "http" && parsed.Scheme != "https" {
+		return nil, fmt.Errorf("invalid -otlp-endpoint: %s", endpoint)
+	}
+	if parsed.Path == "" || parsed.Path == "/" {
+		parsed.Path = "/v1/logs"
+	}
+	if batchLines <= 0 || flushInterval <= 0 {
+		return nil, fmt.Errorf("-otlp-batch-lines and -otlp-flush-interval must be positive")
+	}
+
+	exporter, err := otlploghttp.New(context.Background(),
+		otlploghttp.WithEndpointURL(parsed.String()),
+		otlploghttp.WithTimeout(timeout),
+		otlploghttp.WithHeaders(map[string]string{"User-Agent": *userAgent}),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create OTLP exporter: %v", err)
+	}
+	res, err := resource.New(context.Background(),
+		resource.WithAttributes(attribute.String("service.name", "remote-tail-f"), attribute.String("service.version", version)),
+		resource.WithFromEnv(),
+		resource.WithTelemetrySDK(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("invalid OTLP resource: %v", err)
+	}
+	processor := sdklog.NewBatchProcessor(&countingExporter{Exporter: exporter, status: status},
+		sdklog.WithExportMaxBatchSize(batchLines),
+		sdklog.WithExportInterval(flushInterval),
+		sdklog.WithMaxQueueSize(batchLines*otlpQueueBatches),
+	)
+	provider := sdklog.NewLoggerProvider(sdklog.WithResource(res), sdklog.WithProcessor(processor))
+	return &otlpOutput{
+		provider:      provider,
+		logger:        provider.Logger("remote-tail-f", log.WithInstrumentationVersion(version)),
+		defaultSource: defaultSource,
+	}, nil
+}
+
+// add queues records for export. The file or URL a line comes from is its
+// log.source attribute.
+func (o *otlpOutput) add(records []otlpRecord) {
+	for _, r := range records {
+		var record log.Record
+		record.SetTimestamp(r.time)
+		record.SetObservedTimestamp(r.time)
+		record.SetBody(log.StringValue(r.line))
+		source := r.source
+		if source == "" {
+			source = o.defaultSource
+		}
+		if source != "" {
+			record.AddAttributes(log.String("log.source", source))
+		}
+		o.logger.Emit(context.Background(), record)
+	}
+}
+
+// close exports what is still queued.
+func (o *otlpOutput) close() {
+	if err := o.provider.Shutdown(context.Background()); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to export the last lines over OTLP: %v\n", err)
+	}
+}
+
+// countingExporter counts the records the exporter failed to deliver, after
+// its own retries, as dropped.
+type countingExporter struct {
+	sdklog.Exporter
+	status *runStatus
+}
+
+func (e *countingExporter) Export(ctx context.Context, records []sdklog.Record) error {
+	err := e.Exporter.Export(ctx, records)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to export %d lines over OTLP: %v\n", len(records), err)
+		e.status.mu.Lock()
+		e.status.droppedLines += int64(len(records))
+		e.status.mu.Unlock()
+	}
+	return nil // reported here rather than by the SDK's error handler
+}
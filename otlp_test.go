@@ -0,0 +1,84 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	collogs "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestOtlpExportsLines(t *testing.T) {
+	var mu sync.Mutex
+	var requests []*collogs.ExportLogsServiceRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/logs" || r.Header.Get("Content-Type") != "application/x-protobuf" {
+			t.Errorf("got %s with Content-Type %q", r.URL.Path, r.Header.Get("Content-Type"))
+		}
+		body, _ := io.ReadAll(r.Body)
+		request := &collogs.ExportLogsServiceRequest{}
+		if err := proto.Unmarshal(body, request); err != nil {
+			t.Errorf("invalid request: %v", err)
+		}
+		mu.Lock()
+		requests = append(requests, request)
+		mu.Unlock()
+		w.Header().Set("Content-Type", "application/x-protobuf")
+	}))
+	defer server.Close()
+
+	status := &runStatus{}
+	otlp, err := newOtlpOutput(server.URL, 10, time.Minute, 5*time.Second, "sftp://host/app.log", status)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Unix(1700000000, 0)
+	otlp.add([]otlpRecord{
+		{source: "/logs/a.log", time: now, line: "one"},
+		{time: now, line: "two"},
+	})
+	otlp.close()
+
+	mu.Lock()
+	defer mu.Unlock()
+	type exported struct{ source, line string }
+	var got []exported
+	for _, request := range requests {
+		for _, resourceLogs := range request.ResourceLogs {
+			service := ""
+			for _, attr := range resourceLogs.Resource.Attributes {
+				if attr.Key == "service.name" {
+					service = attr.Value.GetStringValue()
+				}
+			}
+			if service != "remote-tail-f" {
+				t.Errorf("service.name %q", service)
+			}
+			for _, scopeLogs := range resourceLogs.ScopeLogs {
+				for _, record := range scopeLogs.LogRecords {
+					if record.TimeUnixNano != uint64(now.UnixNano()) {
+						t.Errorf("timestamp %d", record.TimeUnixNano)
+					}
+					e := exported{line: record.Body.GetStringValue()}
+					for _, attr := range record.Attributes {
+						if attr.Key == "log.source" {
+							e.source = attr.Value.GetStringValue()
+						}
+					}
+					got = append(got, e)
+				}
+			}
+		}
+	}
+	want := []exported{{"/logs/a.log", "one"}, {"sftp://host/app.log", "two"}}
+	if len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+	if status.droppedLines != 0 {
+		t.Errorf("dropped %d lines", status.droppedLines)
+	}
+}
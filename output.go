@@ -0,0 +1,269 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/prokoma/remote-tail-f/tailer"
+	"golang.org/x/text/encoding/htmlindex"
+)
+
+// newTrimmer returns a function stripping trailing characters from emitted
+// lines according to the -trim mode.
+func newTrimmer(mode string) (func(string) string, error) {
+	switch mode {
+	case "none":
+		return func(line string) string { return line }, nil
+	case "cr":
+		return func(line string) string { return strings.TrimSuffix(line, "\r") }, nil
+	case "space":
+		return func(line string) string { return strings.TrimRight(line, " \t") }, nil
+	case "all":
+		return func(line string) string { return strings.TrimRight(line, " \t\r") }, nil
+	default:
+		return nil, fmt.Errorf("invalid trim mode: %s", mode)
+	}
+}
+
+// newDecoder returns a function converting emitted lines from the -input-encoding
+// charset to UTF-8. Lines are split at newline bytes before decoding, so
+// only charsets keeping ASCII newlines intact are accepted, which rules out
+// UTF-16. A line is always complete, a character cannot be cut in two.
+func newDecoder(name string) (func(string) string, error) {
+	if name == "" {
+		return func(line string) string { return line }, nil
+	}
+	enc, err := htmlindex.Get(name)
+	if err != nil {
+		return nil, fmt.Errorf("unsupported input encoding: %s", name)
+	}
+	canonical, _ := htmlindex.Name(enc)
+	switch canonical {
+	case "utf-8":
+		return func(line string) string { return line }, nil
+	case "utf-16le", "utf-16be", "replacement":
+		return nil, fmt.Errorf("unsupported input encoding: %s, newlines are not single bytes in it", name)
+	}
+	decoder := enc.NewDecoder()
+	return func(line string) string {
+		decoded, err := decoder.String(line)
+		if err != nil {
+			return line
+		}
+		return decoded
+	}, nil
+}
+
+// dropEmpty removes zero-length lines. They were already read past, so the
+// position is not affected.
+func dropEmpty(lines []tailer.Line) []tailer.Line {
+	kept := lines[:0]
+	for _, line := range lines {
+		if line.Text != "" {
+			kept = append(kept, line)
+		}
+	}
+	return kept
+}
+
+// lineDeduplicator drops lines identical to one of the last size lines
+// written.
+type lineDeduplicator struct {
+	size   int
+	recent []string // ring of the last lines written
+	next   int
+	counts map[string]int
+}
+
+func newLineDeduplicator(size int) *lineDeduplicator {
+	return &lineDeduplicator{size: size, counts: map[string]int{}}
+}
+
+// repeated reports whether text is in the window, remembering it if not.
+func (d *lineDeduplicator) repeated(text string) bool {
+	if d.counts[text] > 0 {
+		return true
+	}
+	if len(d.recent) < d.size {
+		d.recent = append(d.recent, text)
+	} else {
+		old := d.recent[d.next]
+		d.counts[old]--
+		if d.counts[old] == 0 {
+			delete(d.counts, old)
+		}
+		d.recent[d.next] = text
+		d.next = (d.next + 1) % d.size
+	}
+	d.counts[text]++
+	return false
+}
+
+// binarySampleSize is how many bytes of a batch are inspected by looksBinary.
+const binarySampleSize = 8192
+
+// looksBinary guesses whether lines came from a non-text file: they contain a
+// NUL byte or more than 10% of the sampled bytes are control characters.
+func looksBinary(lines []tailer.Line) bool {
+	sampled, control := 0, 0
+	for _, line := range lines {
+		for i := 0; i < len(line.Text) && sampled < binarySampleSize; i++ {
+			c := line.Text[i]
+			if c == 0 {
+				return true
+			}
+			if c < 0x20 && c != '\t' && c != '\r' || c == 0x7f {
+				control++
+			}
+			sampled++
+		}
+		if sampled >= binarySampleSize {
+			break
+		}
+	}
+	return sampled > 0 && control*10 > sampled
+}
+
+// sanitize replaces control characters and invalid UTF-8 so a line cannot
+// mess up the terminal.
+func sanitize(line string) string {
+	return strings.Map(func(r rune) rune {
+		if r < 0x20 && r != '\t' || r == 0x7f {
+			return utf8.RuneError
+		}
+		return r
+	}, strings.ToValidUTF8(line, string(utf8.RuneError)))
+}
+
+// binaryFilter implements the -binary modes: warn, skip or sanitize.
+type binaryFilter struct {
+	mode   string
+	warned bool
+}
+
+func newBinaryFilter(mode string) (*binaryFilter, error) {
+	switch mode {
+	case "warn", "skip", "sanitize":
+		return &binaryFilter{mode: mode}, nil
+	default:
+		return nil, fmt.Errorf("invalid binary mode: %s", mode)
+	}
+}
+
+func (f *binaryFilter) filter(lines []tailer.Line) []tailer.Line {
+	if f.mode == "sanitize" {
+		for i := range lines {
+			lines[i].Text = sanitize(lines[i].Text)
+		}
+		return lines
+	}
+	if !looksBinary(lines) {
+		return lines
+	}
+	if !f.warned {
+		if f.mode == "skip" {
+			fmt.Fprintf(os.Stderr, "File looks binary, skipping its content.\n")
+		} else {
+			fmt.Fprintf(os.Stderr, "File looks binary, output may corrupt the terminal.\n")
+		}
+		f.warned = true
+	}
+	if f.mode == "skip" {
+		return nil
+	}
+	return lines
+}
+
+// stringList is a flag that can be given several times.
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ", ")
+}
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+// newRedactor compiles -redact rules of the form PATTERN=REPLACEMENT into a
+// function applying them in order. The rule is split at the first "=" not
+// preceded by a backslash, so a pattern can match "=" as `\=`. The replacement
+// may refer to groups like $1.
+func newRedactor(rules []string) (func(string) string, error) {
+	type redaction struct {
+		re          *regexp.Regexp
+		replacement string
+	}
+	redactions := make([]redaction, 0, len(rules))
+	for _, rule := range rules {
+		sep := -1
+		for i := 0; i < len(rule); i++ {
+			if rule[i] == '\\' {
+				i++
+			} else if rule[i] == '=' {
+				sep = i
+				break
+			}
+		}
+		if sep < 0 {
+			return nil, fmt.Errorf("invalid redact rule %q, expected PATTERN=REPLACEMENT", rule)
+		}
+		re, err := regexp.Compile(rule[:sep])
+		if err != nil {
+			return nil, fmt.Errorf("invalid redact pattern %q: %v", rule[:sep], err)
+		}
+		redactions = append(redactions, redaction{re, rule[sep+1:]})
+	}
+	return func(line string) string {
+		for _, r := range redactions {
+			line = r.re.ReplaceAllString(line, r.replacement)
+		}
+		return line
+	}, nil
+}
+
+// outputFile is the -output-file sink. It can be reopened after an external
+// tool like logrotate moved the file away.
+type outputFile struct {
+	path string
+	file *os.File
+}
+
+func openOutputFile(path string) (*outputFile, error) {
+	f := &outputFile{path: path}
+	return f, f.Reopen()
+}
+
+func (f *outputFile) Write(p []byte) (int, error) {
+	return f.file.Write(p)
+}
+
+// Reopen closes the file and opens path again, creating it if it was moved.
+func (f *outputFile) Reopen() error {
+	file, err := os.OpenFile(f.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		return fmt.Errorf("could not open output file: %v", err)
+	}
+	if f.file != nil {
+		f.file.Close()
+	}
+	f.file = file
+	return nil
+}
+
+func (f *outputFile) Close() error {
+	return f.file.Close()
+}
+
+// formatLine renders a line for output: its text, after the offset with
+// -with-offset.
+func formatLine(line tailer.Line) string {
+	if *withOffset {
+		return fmt.Sprintf("%d\t%s", line.Offset, line.Text)
+	}
+	return line.Text
+}
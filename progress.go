@@ -0,0 +1,46 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/prokoma/remote-tail-f/tailer"
+)
+
+// progressReporter prints the -show-progress lines, how far a backlog replay
+// got and when it will have caught up at the current speed.
+type progressReporter struct {
+	last       time.Time
+	lastOffset int64
+}
+
+func (p *progressReporter) report(t tailer.Tailer) {
+	now := clk.Now()
+	if !p.last.IsZero() && now.Sub(p.last) < *progressInterval {
+		return
+	}
+	size, modTime, ok := t.Size()
+	if !ok {
+		return
+	}
+	offset := t.Offset()
+	percent := 100.0
+	if size > 0 {
+		percent = min(float64(offset)/float64(size)*100, 100)
+	}
+	msg := fmt.Sprintf("Progress: offset %d of %d bytes (%.1f%%)", offset, size, percent)
+	if !modTime.IsZero() {
+		msg += ", modified " + modTime.Format(time.RFC3339)
+	}
+	switch {
+	case offset >= size:
+		msg += ", caught up"
+	case !p.last.IsZero() && offset > p.lastOffset:
+		rate := float64(offset-p.lastOffset) / now.Sub(p.last).Seconds()
+		eta := time.Duration(float64(size-offset) / rate * float64(time.Second))
+		msg += fmt.Sprintf(", ETA %v", eta.Round(time.Second))
+	}
+	fmt.Fprintln(os.Stderr, msg)
+	p.last, p.lastOffset = now, offset
+}
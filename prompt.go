@@ -0,0 +1,39 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"golang.org/x/term"
+)
+
+// promptMu keeps prompts of targets connecting at the same time apart.
+var promptMu sync.Mutex
+
+// promptTerminal asks question on the terminal, also when stdin and stdout
+// are redirected, without echoing the answer unless echo is set.
+func promptTerminal(instruction string, question string, echo bool) (string, error) {
+	promptMu.Lock()
+	defer promptMu.Unlock()
+
+	tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+	if err != nil {
+		return "", fmt.Errorf("could not open terminal: %v", err)
+	}
+	defer tty.Close()
+
+	if instruction != "" {
+		fmt.Fprintln(tty, instruction)
+	}
+	fmt.Fprint(tty, question)
+	if !echo {
+		answer, err := term.ReadPassword(int(tty.Fd()))
+		fmt.Fprintln(tty)
+		return string(answer), err
+	}
+	answer, err := bufio.NewReader(tty).ReadString('\n')
+	return strings.TrimRight(answer, "\r\n"), err
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/prokoma/remote-tail-f/tailer"
+)
+
+// batchQueue is the -queue-lines buffer between fetching and a slower
+// output. When it holds its maximum of lines, -queue-full decides: block
+// stops fetching, drop-oldest drops the oldest queued lines, and spill
+// writes the lines of new batches to a temporary file until there is room.
+type batchQueue struct {
+	maxLines int
+	policy   string
+	status   *runStatus
+	items    []*queuedBatch
+	memLines int // lines held in memory
+	spilled  int // lines in the spill file
+
+	spill       *os.File // created on first use, removed right away
+	spillOffset int64    // end of the spilled data
+}
+
+type queuedBatch struct {
+	batch
+	spilledAt   int64 // offset of the lines in the spill file
+	spilledSize int   // size of the encoded lines, 0 when in memory
+	spilled     int   // number of spilled lines
+}
+
+func newBatchQueue(maxLines int, policy string, status *runStatus) (*batchQueue, error) {
+	switch policy {
+	case "block", "drop-oldest", "spill":
+	default:
+		return nil, fmt.Errorf("invalid -queue-full %q, must be block, drop-oldest or spill", policy)
+	}
+	return &batchQueue{maxLines: maxLines, policy: policy, status: status}, nil
+}
+
+// relay moves batches from in to out through the queue. It closes out once
+// in is closed and everything queued was handed over.
+func (q *batchQueue) relay(in <-chan batch, out chan<- batch) {
+	defer close(out)
+	defer q.close()
+	for {
+		var send chan<- batch
+		var head batch
+		if len(q.items) > 0 {
+			err := q.load(q.items[0])
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Failed to read spilled lines: %v\n", err)
+				q.countDropped(q.items[0].spilled)
+				q.spilled -= q.items[0].spilled
+				q.items[0].spilled = 0
+			}
+			send, head = out, q.items[0].batch
+		}
+		receive := in
+		if q.policy == "block" && q.memLines >= q.maxLines {
+			receive = nil // fetching waits
+		}
+		if receive == nil && send == nil {
+			return
+		}
+		select {
+		case b, ok := <-receive:
+			if !ok {
+				in = nil
+				continue
+			}
+			q.push(b)
+		case send <- head:
+			q.pop()
+		}
+		q.updateStatus()
+	}
+}
+
+func (q *batchQueue) push(b batch) {
+	item := &queuedBatch{batch: b}
+	switch {
+	case q.memLines+len(b.lines) <= q.maxLines || q.policy == "block":
+	case q.policy == "drop-oldest":
+		for _, old := range q.items {
+			if q.memLines+len(b.lines) <= q.maxLines {
+				break
+			}
+			q.countDropped(len(old.lines))
+			q.memLines -= len(old.lines)
+			old.lines = nil
+		}
+		if excess := len(b.lines) - q.maxLines; excess > 0 {
+			q.countDropped(excess)
+			item.lines = b.lines[excess:]
+		}
+	case q.policy == "spill":
+		err := q.store(item)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to spill lines to disk, keeping them in memory: %v\n", err)
+		}
+	}
+	q.memLines += len(item.lines)
+	q.items = append(q.items, item)
+}
+
+func (q *batchQueue) pop() {
+	q.memLines -= len(q.items[0].lines)
+	q.items[0] = nil
+	q.items = q.items[1:]
+	if len(q.items) == 0 && q.spill != nil {
+		// Nothing refers to the spilled data any more.
+		q.spill.Truncate(0)
+		q.spillOffset = 0
+	}
+}
+
+// store moves the lines of item to the spill file.
+func (q *batchQueue) store(item *queuedBatch) error {
+	if q.spill == nil {
+		f, err := os.CreateTemp("", "remote-tail-f-spill-*")
+		if err != nil {
+			return err
+		}
+		os.Remove(f.Name())
+		q.spill = f
+	}
+	data, err := json.Marshal(item.lines)
+	if err != nil {
+		return err
+	}
+	_, err = q.spill.WriteAt(data, q.spillOffset)
+	if err != nil {
+		return err
+	}
+	item.spilledAt, item.spilledSize, item.spilled = q.spillOffset, len(data), len(item.lines)
+	q.spillOffset += int64(len(data))
+	q.spilled += len(item.lines)
+	item.lines = nil
+	return nil
+}
+
+// load brings spilled lines of item back into memory.
+func (q *batchQueue) load(item *queuedBatch) error {
+	if item.spilled == 0 {
+		return nil
+	}
+	data := make([]byte, item.spilledSize)
+	_, err := q.spill.ReadAt(data, item.spilledAt)
+	if err != nil {
+		return err
+	}
+	var lines []tailer.Line
+	err = json.Unmarshal(data, &lines)
+	if err != nil {
+		return err
+	}
+	q.spilled -= item.spilled
+	item.lines, item.spilled = lines, 0
+	q.memLines += len(lines)
+	return nil
+}
+
+func (q *batchQueue) countDropped(n int) {
+	q.status.mu.Lock()
+	q.status.droppedLines += int64(n)
+	q.status.mu.Unlock()
+}
+
+func (q *batchQueue) updateStatus() {
+	q.status.mu.Lock()
+	q.status.queuedLines = int64(q.memLines + q.spilled)
+	q.status.spilledLines = int64(q.spilled)
+	q.status.mu.Unlock()
+}
+
+func (q *batchQueue) close() {
+	if q.spill != nil {
+		q.spill.Close()
+	}
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"slices"
+	"time"
+
+	"github.com/prokoma/remote-tail-f/tailer"
+)
+
+// sortBuffer holds lines for a while and releases them ordered by their
+// leading timestamp, so lines of several files come out in chronological
+// order. A line arriving after newer ones were already released is output
+// in arrival order. Lines without a timestamp stick to the line before them
+// from the same file.
+type sortBuffer struct {
+	window  time.Duration
+	layout  string
+	held    []heldLine
+	states  []heldState
+	batches int
+	lastTS  map[sortSource]time.Time
+}
+
+// sortSource is a file of a target; a tailer following several files sets
+// the Source of its lines.
+type sortSource struct {
+	target tailer.Tailer
+	file   string
+}
+
+type heldLine struct {
+	line    tailer.Line
+	ts      time.Time
+	arrived time.Time
+	batch   int
+}
+
+// heldState is the state after a batch, saved once all its lines are output.
+type heldState struct {
+	batch int
+	state tailer.StateSnapshot
+	held  int
+}
+
+func newSortBuffer(window time.Duration, layout string) *sortBuffer {
+	return &sortBuffer{window: window, layout: layout, lastTS: map[sortSource]time.Time{}}
+}
+
+// add holds lines read from target.
+func (b *sortBuffer) add(target tailer.Tailer, lines []tailer.Line, state tailer.StateSnapshot, now time.Time) {
+	batch := b.batches
+	b.batches++
+	for _, line := range lines {
+		source := sortSource{target: target, file: line.Source}
+		ts, ok := tailer.ParseLineTimestamp(line.Text, b.layout)
+		if ok {
+			b.lastTS[source] = ts
+		} else {
+			ts = b.lastTS[source]
+		}
+		b.held = append(b.held, heldLine{line: line, ts: ts, arrived: now, batch: batch})
+	}
+	b.states = append(b.states, heldState{batch: batch, state: state, held: len(lines)})
+}
+
+// release returns the lines that waited for the window, together with the
+// lines older than them, and the states to save after writing them: those of
+// the batches now output completely, which with several targets may be one
+// per target. With all set everything is released.
+func (b *sortBuffer) release(now time.Time, all bool) ([]tailer.Line, []tailer.StateSnapshot) {
+	var cutoff time.Time
+	ready := false
+	for _, h := range b.held {
+		if all || now.Sub(h.arrived) >= b.window {
+			if !ready || h.ts.After(cutoff) {
+				cutoff = h.ts
+			}
+			ready = true
+		}
+	}
+
+	var out, kept []heldLine
+	for _, h := range b.held {
+		if ready && !h.ts.After(cutoff) {
+			out = append(out, h)
+		} else {
+			kept = append(kept, h)
+		}
+	}
+	b.held = kept
+	slices.SortStableFunc(out, func(x, y heldLine) int { return x.ts.Compare(y.ts) })
+
+	lines := make([]tailer.Line, len(out))
+	for i, h := range out {
+		lines[i] = h.line
+		for j := range b.states {
+			if b.states[j].batch == h.batch {
+				b.states[j].held--
+				break
+			}
+		}
+	}
+
+	var states []tailer.StateSnapshot
+	for len(b.states) > 0 && b.states[0].held == 0 {
+		states = append(states, b.states[0].state)
+		b.states = b.states[1:]
+	}
+	return lines, states
+}
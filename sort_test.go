@@ -0,0 +1,39 @@
+package main
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/prokoma/remote-tail-f/tailer"
+)
+
+func TestSortKeepsContinuationWithItsFile(t *testing.T) {
+	sorter := newSortBuffer(time.Second, time.RFC3339)
+	source := tailer.NewSftpTailer("host:22", "user", "", "/logs/*.log", 1, "")
+	lines := []tailer.Line{
+		{Text: "2024-01-01T00:00:01Z error", Source: "/logs/a.log"},
+		{Text: "  at main", Source: "/logs/a.log"},
+		{Text: "2024-01-01T00:00:05Z later", Source: "/logs/b.log"},
+		// Continues the error in a.log, not the line of b.log before it.
+		{Text: "  at caller", Source: "/logs/a.log"},
+		{Text: "2024-01-01T00:00:03Z earlier", Source: "/logs/b.log"},
+	}
+
+	sorter.add(source, lines, tailer.StateSnapshot{}, time.Now())
+	released, _ := sorter.release(time.Now(), true)
+	var got []string
+	for _, line := range released {
+		got = append(got, line.Text)
+	}
+	want := []string{
+		"2024-01-01T00:00:01Z error",
+		"  at main",
+		"  at caller",
+		"2024-01-01T00:00:03Z earlier",
+		"2024-01-01T00:00:05Z later",
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,54 @@
+//go:build !windows && !plan9
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"log/syslog"
+	"net/url"
+)
+
+var syslogFacilities = map[string]syslog.Priority{
+	"kern": syslog.LOG_KERN, "user": syslog.LOG_USER, "mail": syslog.LOG_MAIL,
+	"daemon": syslog.LOG_DAEMON, "auth": syslog.LOG_AUTH, "syslog": syslog.LOG_SYSLOG,
+	"lpr": syslog.LOG_LPR, "news": syslog.LOG_NEWS, "uucp": syslog.LOG_UUCP,
+	"cron": syslog.LOG_CRON, "authpriv": syslog.LOG_AUTHPRIV, "ftp": syslog.LOG_FTP,
+	"local0": syslog.LOG_LOCAL0, "local1": syslog.LOG_LOCAL1, "local2": syslog.LOG_LOCAL2,
+	"local3": syslog.LOG_LOCAL3, "local4": syslog.LOG_LOCAL4, "local5": syslog.LOG_LOCAL5,
+	"local6": syslog.LOG_LOCAL6, "local7": syslog.LOG_LOCAL7,
+}
+
+var syslogSeverities = map[string]syslog.Priority{
+	"emerg": syslog.LOG_EMERG, "alert": syslog.LOG_ALERT, "crit": syslog.LOG_CRIT,
+	"err": syslog.LOG_ERR, "warning": syslog.LOG_WARNING, "notice": syslog.LOG_NOTICE,
+	"info": syslog.LOG_INFO, "debug": syslog.LOG_DEBUG,
+}
+
+// dialSyslog connects to the syslog server at address, either "local" or
+// udp://host:port or tcp://host:port. Every Write sends one message.
+func dialSyslog(address string, facility string, severity string, tag string) (io.WriteCloser, error) {
+	facilityPriority, ok := syslogFacilities[facility]
+	if !ok {
+		return nil, fmt.Errorf("invalid syslog facility: %s", facility)
+	}
+	severityPriority, ok := syslogSeverities[severity]
+	if !ok {
+		return nil, fmt.Errorf("invalid syslog severity: %s", severity)
+	}
+
+	network, raddr := "", ""
+	if address != "local" {
+		u, err := url.Parse(address)
+		if err != nil || u.Scheme != "udp" && u.Scheme != "tcp" || u.Host == "" {
+			return nil, fmt.Errorf("invalid syslog address: %s", address)
+		}
+		network, raddr = u.Scheme, u.Host
+	}
+
+	w, err := syslog.Dial(network, raddr, facilityPriority|severityPriority, tag)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to syslog: %v", err)
+	}
+	return w, nil
+}
@@ -0,0 +1,12 @@
+//go:build windows || plan9
+
+package main
+
+import (
+	"fmt"
+	"io"
+)
+
+func dialSyslog(address string, facility string, severity string, tag string) (io.WriteCloser, error) {
+	return nil, fmt.Errorf("syslog is not supported on this platform")
+}
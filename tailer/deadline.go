@@ -0,0 +1,85 @@
+package tailer
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+// deadlineFS bounds every operation on a remoteFS by a timeout. Neither SFTP
+// nor SSH sessions are context aware, so when an operation takes longer,
+// abort closes the connection, which makes it return. Reads are bounded one
+// by one, a long download goes on as long as data keeps coming.
+type deadlineFS struct {
+	fs      remoteFS
+	timeout time.Duration
+	abort   func()
+}
+
+func newDeadlineFS(fs remoteFS, timeout time.Duration, abort func()) *deadlineFS {
+	return &deadlineFS{fs: fs, timeout: timeout, abort: abort}
+}
+
+// withDeadline runs op, aborting it after the timeout.
+func withDeadline[T any](d *deadlineFS, op func() (T, error)) (T, error) {
+	timer := time.AfterFunc(d.timeout, d.abort)
+	result, err := op()
+	if !timer.Stop() && err != nil {
+		err = fmt.Errorf("no answer from the server within %v: %w", d.timeout, os.ErrDeadlineExceeded)
+	}
+	return result, err
+}
+
+func (d *deadlineFS) Glob(pattern string) ([]string, error) {
+	return withDeadline(d, func() ([]string, error) { return d.fs.Glob(pattern) })
+}
+
+func (d *deadlineFS) Stat(path string) (os.FileInfo, error) {
+	return withDeadline(d, func() (os.FileInfo, error) { return d.fs.Stat(path) })
+}
+
+func (d *deadlineFS) Lstat(path string) (os.FileInfo, error) {
+	return withDeadline(d, func() (os.FileInfo, error) { return d.fs.Lstat(path) })
+}
+
+func (d *deadlineFS) ReadLink(path string) (string, error) {
+	return withDeadline(d, func() (string, error) { return d.fs.ReadLink(path) })
+}
+
+func (d *deadlineFS) Open(path string) (remoteFile, error) {
+	file, err := withDeadline(d, func() (remoteFile, error) { return d.fs.Open(path) })
+	if err != nil {
+		return nil, err
+	}
+	return &deadlineFile{file: file, fs: d}, nil
+}
+
+func (d *deadlineFS) Close() error {
+	return d.fs.Close()
+}
+
+type deadlineFile struct {
+	file remoteFile
+	fs   *deadlineFS
+}
+
+func (f *deadlineFile) Read(p []byte) (int, error) {
+	return withDeadline(f.fs, func() (int, error) { return f.file.Read(p) })
+}
+
+func (f *deadlineFile) ReadAt(p []byte, offset int64) (int, error) {
+	return withDeadline(f.fs, func() (int, error) { return f.file.ReadAt(p, offset) })
+}
+
+func (f *deadlineFile) Seek(offset int64, whence int) (int64, error) {
+	return withDeadline(f.fs, func() (int64, error) { return f.file.Seek(offset, whence) })
+}
+
+func (f *deadlineFile) Stat() (os.FileInfo, error) {
+	return withDeadline(f.fs, func() (os.FileInfo, error) { return f.file.Stat() })
+}
+
+func (f *deadlineFile) Close() error {
+	_, err := withDeadline(f.fs, func() (struct{}, error) { return struct{}{}, f.file.Close() })
+	return err
+}
@@ -0,0 +1,60 @@
+package tailer
+
+import (
+	"errors"
+	"os"
+	"sync"
+	"testing"
+	"time"
+)
+
+// hangingFS is a remoteFS whose server stopped answering: Stat blocks until
+// the connection is closed.
+type hangingFS struct {
+	remoteFS
+	closed chan struct{}
+	once   sync.Once
+}
+
+func (fs *hangingFS) Stat(path string) (os.FileInfo, error) {
+	<-fs.closed
+	return nil, errors.New("connection lost")
+}
+
+func (fs *hangingFS) Lstat(path string) (os.FileInfo, error) {
+	return nil, os.ErrNotExist
+}
+
+func (fs *hangingFS) closeConnection() {
+	fs.once.Do(func() { close(fs.closed) })
+}
+
+func TestDeadlineFSAbortsHangingOperation(t *testing.T) {
+	hanging := &hangingFS{closed: make(chan struct{})}
+	d := newDeadlineFS(hanging, 100*time.Millisecond, hanging.closeConnection)
+
+	start := time.Now()
+	_, err := d.Stat("app.log")
+	if !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Fatalf("got error %v, want os.ErrDeadlineExceeded", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Stat returned after %v with a timeout of 100ms", elapsed)
+	}
+}
+
+func TestDeadlineFSKeepsAnsweredOperation(t *testing.T) {
+	hanging := &hangingFS{closed: make(chan struct{})}
+	d := newDeadlineFS(hanging, 100*time.Millisecond, hanging.closeConnection)
+
+	_, err := d.Lstat("app.log")
+	if !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("got error %v, want os.ErrNotExist", err)
+	}
+	time.Sleep(200 * time.Millisecond)
+	select {
+	case <-hanging.closed:
+		t.Error("connection closed after the operation returned in time")
+	default:
+	}
+}
@@ -0,0 +1,169 @@
+package tailer
+
+import (
+	"compress/bzip2"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"mime"
+	"path"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Compressed files can't be read from the middle, so they are downloaded
+// whole whenever they changed and the read position counts decompressed
+// bytes.
+
+// fileStamp tells a compressed file apart by its size and modification
+// time, which rotation renaming it keeps.
+type fileStamp struct {
+	size    int64
+	modTime int64 // Unix nanoseconds
+}
+
+// compressedRead is how far a compressed file was read.
+type compressedRead struct {
+	path     string
+	offset   int64
+	complete bool // read to its end
+}
+
+// compressedState is a compressedRead in the state file.
+type compressedState struct {
+	Path     string    `json:"path"`
+	Size     int64     `json:"size"`
+	ModTime  time.Time `json:"modTime"`
+	Offset   int64     `json:"offset"`
+	Complete bool      `json:"complete,omitempty"`
+}
+
+// readBefore returns how far the compressed file with stamp was read, under
+// path or under the name it had before being renamed on rotation. A file not
+// read before is read from its start: compressed files are replaced rather
+// than appended to.
+func (t *TailerBase) readBefore(path string, stamp fileStamp) compressedRead {
+	if t.stampsSeen == nil {
+		t.stampsSeen = map[fileStamp]bool{}
+	}
+	t.stampsSeen[stamp] = true
+	read, ok := t.compressed[stamp]
+	if !ok {
+		return compressedRead{path: path}
+	}
+	read.path = path
+	t.compressed[stamp] = read
+	return read
+}
+
+// markRead remembers how far the compressed file at path was read, so it is
+// not downloaded again once read to its end, until it changes.
+func (t *TailerBase) markRead(path string, stamp fileStamp, offset int64, complete bool) {
+	if t.compressed == nil {
+		t.compressed = map[fileStamp]compressedRead{}
+	}
+	t.compressed[stamp] = compressedRead{path: path, offset: offset, complete: complete}
+}
+
+// rewindCompressed makes the compressed file at path be read again from
+// offset.
+func (t *TailerBase) rewindCompressed(path string, offset int64) {
+	for stamp, read := range t.compressed {
+		if read.path == path {
+			t.compressed[stamp] = compressedRead{path: path, offset: offset}
+		}
+	}
+}
+
+// pruneCompressed forgets the compressed files not seen since the last
+// call, after a poll that looked at all files.
+func (t *TailerBase) pruneCompressed() {
+	for stamp := range t.compressed {
+		if !t.stampsSeen[stamp] {
+			delete(t.compressed, stamp)
+		}
+	}
+	t.stampsSeen = nil
+}
+
+// compressionFromName detects the compression of a file from its extension,
+// "" means not compressed.
+func compressionFromName(name string) string {
+	switch path.Ext(name) {
+	case ".gz":
+		return "gzip"
+	case ".bz2":
+		return "bzip2"
+	case ".zst":
+		return "zstd"
+	default:
+		return ""
+	}
+}
+
+// compressionFromContentType detects the compression of an HTTP response
+// body, "" means not compressed or unknown.
+func compressionFromContentType(contentType string) string {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return ""
+	}
+	switch mediaType {
+	case "application/gzip", "application/x-gzip":
+		return "gzip"
+	case "application/x-bzip2":
+		return "bzip2"
+	case "application/zstd":
+		return "zstd"
+	default:
+		return ""
+	}
+}
+
+func decompressReader(compression string, r io.Reader) (io.ReadCloser, error) {
+	switch compression {
+	case "gzip":
+		return gzip.NewReader(r)
+	case "bzip2":
+		return io.NopCloser(bzip2.NewReader(r)), nil
+	case "zstd":
+		d, err := zstd.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+		return d.IOReadCloser(), nil
+	default:
+		return nil, fmt.Errorf("unknown compression: %s", compression)
+	}
+}
+
+// readCompressedLines decompresses r and returns the complete lines after
+// offset, advancing it. On a read error it returns the lines read so far.
+func (t *TailerBase) readCompressedLines(name string, r io.Reader, compression string, offset *int64) ([]Line, error) {
+	d, err := decompressReader(compression, r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress %s: %v", name, err)
+	}
+	defer d.Close()
+
+	body, err := io.ReadAll(d)
+	if err != nil {
+		err = fmt.Errorf("failed to decompress %s: %v", name, err)
+	}
+
+	if int64(len(body)) < *offset {
+		if err != nil {
+			return nil, err // may just be cut short
+		}
+		err := t.truncated(name, int64(len(body)), offset, "File %s truncated.", name)
+		if err != nil {
+			return nil, err
+		}
+	}
+	body = body[*offset:]
+
+	lines, _ := splitLines(body, offset)
+
+	return lines, err
+}
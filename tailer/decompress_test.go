@@ -0,0 +1,158 @@
+package tailer
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// memFS is a remoteFS serving files from memory.
+type memFS struct {
+	files map[string]memFileInfo
+	opens map[string]int
+}
+
+type memFileInfo struct {
+	data    []byte
+	modTime time.Time
+}
+
+func (m *memFS) Glob(pattern string) ([]string, error) {
+	var matches []string
+	for name := range m.files {
+		if ok, _ := path.Match(pattern, name); ok {
+			matches = append(matches, name)
+		}
+	}
+	return matches, nil
+}
+
+func (m *memFS) Stat(name string) (os.FileInfo, error) {
+	file, ok := m.files[name]
+	if !ok {
+		return nil, os.ErrNotExist
+	}
+	return shellFileInfo{name: path.Base(name), size: int64(len(file.data)), modTime: file.modTime}, nil
+}
+
+func (m *memFS) Lstat(name string) (os.FileInfo, error) {
+	return m.Stat(name)
+}
+
+func (m *memFS) ReadLink(name string) (string, error) {
+	return "", &fs.PathError{Op: "readlink", Path: name, Err: fs.ErrInvalid}
+}
+
+func (m *memFS) Open(name string) (remoteFile, error) {
+	info, err := m.Stat(name)
+	if err != nil {
+		return nil, err
+	}
+	if m.opens == nil {
+		m.opens = map[string]int{}
+	}
+	m.opens[name]++
+	return memFile{Reader: bytes.NewReader(m.files[name].data), info: info}, nil
+}
+
+func (m *memFS) Close() error {
+	return nil
+}
+
+type memFile struct {
+	*bytes.Reader
+	info os.FileInfo
+}
+
+func (f memFile) Stat() (os.FileInfo, error) { return f.info, nil }
+func (f memFile) Close() error               { return nil }
+
+func gzipped(t *testing.T, text string) []byte {
+	t.Helper()
+	var b bytes.Buffer
+	w := gzip.NewWriter(&b)
+	w.Write([]byte(text))
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return b.Bytes()
+}
+
+func texts(lines []Line) string {
+	var b strings.Builder
+	for _, line := range lines {
+		b.WriteString(line.Text + "\n")
+	}
+	return b.String()
+}
+
+func newMemTailer(files *memFS, stateFile string) *SftpTailer {
+	tailer := NewSftpTailer("host:22", "user", "", "/logs/app.log*", 1, stateFile)
+	tailer.SetDecompress(true)
+	tailer.client = files
+	return tailer
+}
+
+func TestUnchangedCompressedFileIsSkipped(t *testing.T) {
+	modTime := time.Unix(1700000000, 0)
+	files := &memFS{files: map[string]memFileInfo{
+		"/logs/app.log.1.gz": {data: gzipped(t, "a\nb\n"), modTime: modTime},
+	}}
+	tailer := newMemTailer(files, "")
+
+	lines, err := tailer.FetchNewLines(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := texts(lines); got != "a\nb\n" {
+		t.Fatalf("first poll got %q", got)
+	}
+
+	lines, err = tailer.FetchNewLines(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(lines) != 0 {
+		t.Errorf("second poll got %q", texts(lines))
+	}
+	if files.opens["/logs/app.log.1.gz"] != 2 {
+		t.Errorf("opened %d times", files.opens["/logs/app.log.1.gz"])
+	}
+}
+
+func TestRotatedCompressedFileIsRecognized(t *testing.T) {
+	stateFile := filepath.Join(t.TempDir(), "state.json")
+	first := memFileInfo{data: gzipped(t, "a\nb\n"), modTime: time.Unix(1700000000, 0)}
+	files := &memFS{files: map[string]memFileInfo{"/logs/app.log.1.gz": first}}
+	tailer := newMemTailer(files, stateFile)
+	if _, err := tailer.FetchNewLines(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if err := tailer.SaveState(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Rotation renames app.log.1.gz and reuses its name for newer lines.
+	files.files = map[string]memFileInfo{
+		"/logs/app.log.1.gz": {data: gzipped(t, "c\n"), modTime: time.Unix(1700003600, 0)},
+		"/logs/app.log.2.gz": first,
+	}
+	tailer = newMemTailer(files, stateFile)
+	if _, err := tailer.LoadState(); err != nil {
+		t.Fatal(err)
+	}
+	lines, err := tailer.FetchNewLines(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := texts(lines); got != "c\n" {
+		t.Errorf("after rotation got %q, want only the new file", got)
+	}
+}
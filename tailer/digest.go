@@ -0,0 +1,142 @@
+package tailer
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"net/http"
+	"strings"
+)
+
+// digestAuth answers HTTP Digest challenges (RFC 7616). The nonce is kept
+// across requests, so only the first request and those after the server
+// declares the nonce stale need a second round trip.
+type digestAuth struct {
+	username  string
+	password  string
+	realm     string
+	nonce     string
+	opaque    string
+	algorithm string
+	qop       string
+	nc        int
+}
+
+// parseDigestChallenge returns the parameters of the first Digest challenge
+// among the WWW-Authenticate header values.
+func parseDigestChallenge(headers []string) (map[string]string, bool) {
+	for _, header := range headers {
+		scheme, rest, _ := strings.Cut(strings.TrimSpace(header), " ")
+		if !strings.EqualFold(scheme, "Digest") {
+			continue
+		}
+		params := map[string]string{}
+		for rest != "" {
+			var key, value string
+			key, rest, _ = strings.Cut(strings.TrimLeft(rest, " ,"), "=")
+			if strings.HasPrefix(rest, `"`) {
+				value, rest = unquoteDigestValue(rest[1:])
+			} else {
+				value, rest, _ = strings.Cut(rest, ",")
+			}
+			params[strings.ToLower(strings.TrimSpace(key))] = strings.TrimSpace(value)
+		}
+		return params, params["nonce"] != ""
+	}
+	return nil, false
+}
+
+// unquoteDigestValue reads a quoted string up to its closing quote, which was
+// already stripped from the start, and returns the value and the rest.
+func unquoteDigestValue(s string) (string, string) {
+	var value strings.Builder
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			if i+1 < len(s) {
+				i++
+				value.WriteByte(s[i])
+			}
+		case '"':
+			return value.String(), s[i+1:]
+		default:
+			value.WriteByte(s[i])
+		}
+	}
+	return value.String(), ""
+}
+
+func newDigestAuth(username string, password string, challenge map[string]string) (*digestAuth, error) {
+	algorithm := challenge["algorithm"]
+	if algorithm == "" {
+		algorithm = "MD5"
+	}
+	switch strings.ToUpper(algorithm) {
+	case "MD5", "MD5-SESS", "SHA-256", "SHA-256-SESS":
+	default:
+		return nil, fmt.Errorf("unsupported digest algorithm: %s", algorithm)
+	}
+	qop := ""
+	if challenge["qop"] != "" {
+		for _, option := range strings.Split(challenge["qop"], ",") {
+			if strings.TrimSpace(option) == "auth" {
+				qop = "auth"
+			}
+		}
+		if qop == "" {
+			return nil, fmt.Errorf("unsupported digest qop: %s", challenge["qop"])
+		}
+	}
+	return &digestAuth{
+		username:  username,
+		password:  password,
+		realm:     challenge["realm"],
+		nonce:     challenge["nonce"],
+		opaque:    challenge["opaque"],
+		algorithm: algorithm,
+		qop:       qop,
+	}, nil
+}
+
+func (a *digestAuth) hash(parts ...string) string {
+	var h hash.Hash
+	if strings.HasPrefix(strings.ToUpper(a.algorithm), "SHA-256") {
+		h = sha256.New()
+	} else {
+		h = md5.New()
+	}
+	h.Write([]byte(strings.Join(parts, ":")))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// authorize returns the Authorization header value for req.
+func (a *digestAuth) authorize(req *http.Request) string {
+	a.nc++
+	nc := fmt.Sprintf("%08x", a.nc)
+	cnonce := newRequestID()
+	uri := req.URL.RequestURI()
+
+	ha1 := a.hash(a.username, a.realm, a.password)
+	if strings.HasSuffix(strings.ToUpper(a.algorithm), "-SESS") {
+		ha1 = a.hash(ha1, a.nonce, cnonce)
+	}
+	ha2 := a.hash(req.Method, uri)
+	var response string
+	if a.qop != "" {
+		response = a.hash(ha1, a.nonce, nc, cnonce, a.qop, ha2)
+	} else {
+		response = a.hash(ha1, a.nonce, ha2)
+	}
+
+	header := fmt.Sprintf(`Digest username=%q, realm=%q, nonce=%q, uri=%q, algorithm=%s, response=%q`,
+		a.username, a.realm, a.nonce, uri, a.algorithm, response)
+	if a.opaque != "" {
+		header += fmt.Sprintf(`, opaque=%q`, a.opaque)
+	}
+	if a.qop != "" {
+		header += fmt.Sprintf(`, qop=%s, nc=%s, cnonce=%q`, a.qop, nc, cnonce)
+	}
+	return header
+}
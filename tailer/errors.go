@@ -0,0 +1,82 @@
+package tailer
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+)
+
+// Failures are classified so callers can tell them apart with errors.Is:
+// ErrConnect, ErrAuth, ErrTruncated for a truncated file under
+// TruncateError, os.ErrNotExist for a missing file, and *StatusError for
+// other unexpected HTTP responses.
+var (
+	ErrConnect   = errors.New("connection failed")
+	ErrAuth      = errors.New("authentication failed")
+	ErrTruncated = errors.New("file truncated")
+)
+
+// classifiedError marks err as being of kind without changing its message.
+type classifiedError struct {
+	kind error
+	err  error
+}
+
+func classify(kind error, err error) error {
+	return &classifiedError{kind: kind, err: err}
+}
+
+func (e *classifiedError) Error() string {
+	return e.err.Error()
+}
+
+func (e *classifiedError) Is(target error) bool {
+	return target == e.kind
+}
+
+func (e *classifiedError) Unwrap() error {
+	return e.err
+}
+
+// BackoffError is returned instead of connecting while new connects are put
+// off after failed ones. It wraps the error of the last attempt, Until is
+// when the next one can be made.
+type BackoffError struct {
+	Err   error
+	Until time.Time
+}
+
+func (e *BackoffError) Error() string {
+	return fmt.Sprintf("%v (next attempt in %v)", e.Err, time.Until(e.Until).Round(time.Second))
+}
+
+func (e *BackoffError) Unwrap() error {
+	return e.Err
+}
+
+// StatusError is an unexpected HTTP response status.
+type StatusError struct {
+	StatusCode int
+	Status     string
+}
+
+func statusError(resp *http.Response) *StatusError {
+	return &StatusError{StatusCode: resp.StatusCode, Status: resp.Status}
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("unexpected HTTP status: %s", e.Status)
+}
+
+func (e *StatusError) Is(target error) bool {
+	switch target {
+	case ErrAuth:
+		return e.StatusCode == http.StatusUnauthorized || e.StatusCode == http.StatusForbidden
+	case os.ErrNotExist:
+		return e.StatusCode == http.StatusNotFound || e.StatusCode == http.StatusGone
+	default:
+		return false
+	}
+}
@@ -0,0 +1,80 @@
+package tailer
+
+import "fmt"
+
+type EventKind int
+
+const (
+	EventTruncated EventKind = iota
+	EventRangeNotSupported
+	EventEmptyResponse
+	EventOffsetClamped
+	EventFileAdded
+	EventFileRemoved
+	EventCommandExited
+	EventFailover
+	EventStreamClosed
+	EventNotModified
+	EventSftpUnavailable
+	EventFileSwitched
+	EventWaitingForFile
+	EventFileAppeared
+)
+
+func (k EventKind) String() string {
+	switch k {
+	case EventTruncated:
+		return "truncated"
+	case EventRangeNotSupported:
+		return "range-not-supported"
+	case EventEmptyResponse:
+		return "empty-response"
+	case EventOffsetClamped:
+		return "offset-clamped"
+	case EventFileAdded:
+		return "file-added"
+	case EventFileRemoved:
+		return "file-removed"
+	case EventCommandExited:
+		return "command-exited"
+	case EventFailover:
+		return "failover"
+	case EventStreamClosed:
+		return "stream-closed"
+	case EventNotModified:
+		return "not-modified"
+	case EventSftpUnavailable:
+		return "sftp-unavailable"
+	case EventFileSwitched:
+		return "file-switched"
+	case EventWaitingForFile:
+		return "waiting-for-file"
+	case EventFileAppeared:
+		return "file-appeared"
+	default:
+		return fmt.Sprintf("EventKind(%d)", int(k))
+	}
+}
+
+// Event reports a condition a tailer recovered from on its own, such as a
+// truncated file. Path names the affected file where the tailer knows it.
+// Message is empty for events only worth counting, like EventNotModified.
+type Event struct {
+	Kind    EventKind
+	Path    string
+	Message string
+}
+
+type EventHandler func(Event)
+
+// SetEventHandler installs a handler for events, by default they are dropped.
+func (t *TailerBase) SetEventHandler(handler EventHandler) {
+	t.eventHandler = handler
+}
+
+func (t *TailerBase) emitEvent(kind EventKind, path string, format string, args ...any) {
+	if t.eventHandler == nil {
+		return
+	}
+	t.eventHandler(Event{Kind: kind, Path: path, Message: fmt.Sprintf(format, args...)})
+}
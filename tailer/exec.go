@@ -0,0 +1,168 @@
+package tailer
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// ExecTailer runs a command on a remote host over SSH and follows its standard
+// output, e.g. "journalctl -f -o cat". The offset counts the bytes received
+// from the command, so it only tells how much output has been consumed. When
+// the command exits it is started again on the next poll.
+type ExecTailer struct {
+	TailerBase
+	sshConnector
+
+	command   string
+	sshClient *ssh.Client
+	session   *ssh.Session
+	receiver  *receiver[byte]
+	stderr    tailBuffer
+}
+
+func NewExecTailer(address string, username string, password string, command string, requestTimeoutSec int, stateFilePath string) *ExecTailer {
+	return &ExecTailer{
+		TailerBase: TailerBase{
+			identity:      fmt.Sprintf("ssh://%s@%s %s", username, address, command),
+			stateFilePath: stateFilePath,
+			lastOffset:    0,
+		},
+		sshConnector: newSshConnector(address, username, password, requestTimeoutSec),
+		command:      command,
+	}
+}
+
+func (t *ExecTailer) start(ctx context.Context) error {
+	sshClient, err := t.dialSsh(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to connect: %w", err)
+	}
+
+	session, err := sshClient.NewSession()
+	if err != nil {
+		sshClient.Close()
+		return fmt.Errorf("failed to open session: %v", err)
+	}
+	stdout, err := session.StdoutPipe()
+	if err != nil {
+		session.Close()
+		sshClient.Close()
+		return err
+	}
+	t.stderr.Reset()
+	session.Stderr = &t.stderr
+
+	err = session.Start(t.command)
+	if err != nil {
+		session.Close()
+		sshClient.Close()
+		return fmt.Errorf("failed to start %q: %v", t.command, err)
+	}
+
+	t.sshClient = sshClient
+	t.session = session
+	t.receiver = receiveBytes(stdout)
+	return nil
+}
+
+func (t *ExecTailer) stop() {
+	if t.session != nil {
+		// Commands like "tail -F" never exit by themselves, ask the server
+		// to terminate them rather than leaving them running.
+		t.session.Signal(ssh.SIGTERM)
+		t.session.Close()
+		t.session = nil
+	}
+	if t.sshClient != nil {
+		t.sshClient.Close()
+		t.sshClient = nil
+	}
+}
+
+func (t *ExecTailer) Close() error {
+	t.stop()
+	return nil
+}
+
+func (t *ExecTailer) SetPosition(ctx context.Context, offset int64, whence int) error {
+	if offset == 0 && whence == io.SeekEnd {
+		return nil // only new data is ever read
+	}
+	return fmt.Errorf("cannot change position in command output")
+}
+
+func (t *ExecTailer) CheckPosition(ctx context.Context) error {
+	return nil
+}
+
+func (t *ExecTailer) SetPositionLastLines(ctx context.Context, n int) error {
+	return fmt.Errorf("cannot start at the last lines of command output")
+}
+
+func (t *ExecTailer) SetPositionAtTime(ctx context.Context, since time.Time, layout string) error {
+	return fmt.Errorf("cannot change position in command output")
+}
+
+func (t *ExecTailer) Rewind(lines []Line) error {
+	return fmt.Errorf("cannot rewind command output")
+}
+
+func (t *ExecTailer) FetchNewLines(ctx context.Context) ([]Line, error) {
+	if t.session == nil {
+		err := t.start(ctx)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	lines, exited, exitErr := takeLines(t.receiver, &t.lastOffset)
+	if exited {
+		if exitErr == nil {
+			exitErr = t.session.Wait()
+		}
+		t.stop()
+		if exitErr != nil {
+			t.emitEvent(EventCommandExited, "", "Command %q failed: %v %s", t.command, exitErr, bytes.TrimSpace(t.stderr.Bytes()))
+		} else {
+			t.emitEvent(EventCommandExited, "", "Command %q exited.", t.command)
+		}
+	}
+	return lines, nil
+}
+
+// tailBuffer keeps the last few KiB written to it, used to report what a
+// remote command printed to stderr before exiting.
+type tailBuffer struct {
+	mu  sync.Mutex
+	buf []byte
+}
+
+const tailBufferSize = 4096
+
+func (b *tailBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.buf = append(b.buf, p...)
+	if len(b.buf) > tailBufferSize {
+		b.buf = b.buf[len(b.buf)-tailBufferSize:]
+	}
+	return len(p), nil
+}
+
+func (b *tailBuffer) Bytes() []byte {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return bytes.Clone(b.buf)
+}
+
+func (b *tailBuffer) Reset() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.buf = nil
+}
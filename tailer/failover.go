@@ -0,0 +1,157 @@
+package tailer
+
+import (
+	"context"
+	"maps"
+	"time"
+)
+
+// FailoverTailer follows the same file on several mirrors, switching to the
+// next mirror after maxFailures failed fetches in a row. The read position is
+// carried over as is, so the mirrors must serve byte-identical content.
+type FailoverTailer struct {
+	tailers     []Tailer
+	active      int
+	maxFailures int
+	failures    int
+}
+
+// NewFailoverTailer starts with the first of tailers, which must all be
+// created by this package.
+func NewFailoverTailer(tailers []Tailer, maxFailures int) *FailoverTailer {
+	return &FailoverTailer{
+		tailers:     tailers,
+		maxFailures: max(maxFailures, 1),
+	}
+}
+
+// baseOf returns the TailerBase embedded in every tailer of this package.
+func baseOf(t Tailer) *TailerBase {
+	return t.(interface{ base() *TailerBase }).base()
+}
+
+func (t *TailerBase) base() *TailerBase {
+	return t
+}
+
+func (t *FailoverTailer) current() Tailer {
+	return t.tailers[t.active]
+}
+
+func (t *FailoverTailer) FetchNewLines(ctx context.Context) ([]Line, error) {
+	lines, err := t.current().FetchNewLines(ctx)
+	if err == nil {
+		t.failures = 0
+		return lines, nil
+	}
+	t.failures++
+	if t.failures >= t.maxFailures && len(t.tailers) > 1 && ctx.Err() == nil {
+		t.switchToNext()
+	}
+	return lines, err
+}
+
+// switchToNext moves the read position to the next mirror. The ETag is left
+// behind, it is specific to the server.
+func (t *FailoverTailer) switchToNext() {
+	from := baseOf(t.current())
+	t.active = (t.active + 1) % len(t.tailers)
+	t.failures = 0
+	to := baseOf(t.current())
+
+	to.lastOffset = from.lastOffset
+	to.offsets = maps.Clone(from.offsets)
+	to.cursor = from.cursor
+	to.lastTime = from.lastTime
+	to.emitEvent(EventFailover, "", "Switching to %s after %d failures.", to.identity, t.maxFailures)
+}
+
+func (t *FailoverTailer) SetPosition(ctx context.Context, offset int64, whence int) error {
+	return t.current().SetPosition(ctx, offset, whence)
+}
+
+func (t *FailoverTailer) SetPositionLastLines(ctx context.Context, n int) error {
+	return t.current().SetPositionLastLines(ctx, n)
+}
+
+func (t *FailoverTailer) SetPositionAtTime(ctx context.Context, since time.Time, layout string) error {
+	return t.current().SetPositionAtTime(ctx, since, layout)
+}
+
+func (t *FailoverTailer) Offset() int64 {
+	return t.current().Offset()
+}
+
+func (t *FailoverTailer) Rewind(lines []Line) error {
+	return t.current().Rewind(lines)
+}
+
+// SetStateDir names the state file after the first mirror, all mirrors share
+// it.
+func (t *FailoverTailer) SetStateDir(dir string) error {
+	err := t.tailers[0].SetStateDir(dir)
+	if err != nil {
+		return err
+	}
+	for _, tailer := range t.tailers[1:] {
+		baseOf(tailer).stateFilePath = baseOf(t.tailers[0]).stateFilePath
+	}
+	return nil
+}
+
+func (t *FailoverTailer) LoadState() (bool, error) {
+	return t.current().LoadState()
+}
+
+func (t *FailoverTailer) CheckPosition(ctx context.Context) error {
+	return t.current().CheckPosition(ctx)
+}
+
+func (t *FailoverTailer) SaveState() error {
+	return t.current().SaveState()
+}
+
+func (t *FailoverTailer) SnapshotState() (StateSnapshot, error) {
+	return t.current().SnapshotState()
+}
+
+func (t *FailoverTailer) SetEventHandler(handler EventHandler) {
+	for _, tailer := range t.tailers {
+		tailer.SetEventHandler(handler)
+	}
+}
+
+func (t *FailoverTailer) SetRateLimit(bytesPerSec int) {
+	for _, tailer := range t.tailers {
+		tailer.SetRateLimit(bytesPerSec)
+	}
+}
+
+func (t *FailoverTailer) SetTruncatePolicy(policy TruncatePolicy) {
+	for _, tailer := range t.tailers {
+		tailer.SetTruncatePolicy(policy)
+	}
+}
+
+func (t *FailoverTailer) Size() (int64, time.Time, bool) {
+	return t.current().Size()
+}
+
+func (t *FailoverTailer) SetCheckpointTime(ts time.Time) {
+	t.current().SetCheckpointTime(ts)
+}
+
+func (t *FailoverTailer) CheckpointTime() time.Time {
+	return t.current().CheckpointTime()
+}
+
+func (t *FailoverTailer) Close() error {
+	var firstErr error
+	for _, tailer := range t.tailers {
+		err := tailer.Close()
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
@@ -0,0 +1,63 @@
+package tailer
+
+import (
+	"context"
+	"time"
+)
+
+// Follow polls t every interval and sends the new lines on the returned
+// channel, for embedding the tailer without writing the polling loop.
+//
+// The lines channel is unbuffered: the next poll starts only after the
+// consumer took every line of the previous one, so a slow consumer slows
+// polling down rather than lines piling up in memory. The state is saved
+// after the lines of a poll were all taken, a crash may repeat at most the
+// lines of one poll.
+//
+// The errors channel has room for one error. Errors of later polls are
+// dropped while it is full, a consumer not interested in them doesn't have to
+// read it. Polling goes on after errors.
+//
+// Both channels are closed once ctx is done, the state is not saved past
+// lines not taken by then. The tailer is not closed.
+func Follow(ctx context.Context, t Tailer, interval time.Duration) (<-chan Line, <-chan error) {
+	lines := make(chan Line)
+	errs := make(chan error, 1)
+	report := func(err error) {
+		select {
+		case errs <- err:
+		default:
+		}
+	}
+	go func() {
+		defer close(errs)
+		defer close(lines)
+		for {
+			fetched, err := t.FetchNewLines(ctx)
+			if ctx.Err() != nil {
+				return
+			}
+			for _, line := range fetched {
+				select {
+				case lines <- line:
+				case <-ctx.Done():
+					return
+				}
+			}
+			if err != nil {
+				report(err)
+			}
+			if len(fetched) > 0 {
+				if err := t.SaveState(); err != nil {
+					report(err)
+				}
+			}
+			select {
+			case <-time.After(interval):
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return lines, errs
+}
@@ -0,0 +1,302 @@
+package tailer
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/net/http2"
+)
+
+// GrpcTailer follows a server-streaming gRPC method that sends log lines, at
+// a URL like grpc://host:port/logs.v1.LogStream/Tail, or grpcs:// for TLS.
+// It speaks the following contract, which servers implement or adapt to:
+//
+//	service LogStream {
+//	  rpc Tail(TailRequest) returns (stream TailResponse);
+//	}
+//	message TailRequest {
+//	  string cursor = 1;     // resume after this cursor, "" for no cursor
+//	  bool from_start = 2;   // without cursor: the oldest lines, else new ones only
+//	}
+//	message TailResponse {
+//	  string line = 1;
+//	  string cursor = 2;     // position after line, kept in the state
+//	}
+//
+// The stream is kept open across polls. When it ends or fails, it is opened
+// again on the next poll with the cursor of the last line returned, so no
+// lines are missed as long as the server honors cursors. The offset counts
+// the bytes of the lines received.
+type GrpcTailer struct {
+	TailerBase
+
+	target            string // the URL called, https:// or h2c http://
+	requestTimeoutSec int
+	client            *http.Client
+	tlsConfig         *tls.Config
+	userAgent         string
+	fromStart         bool
+	body              io.ReadCloser
+	cancel            context.CancelFunc
+	receiver          *receiver[grpcLine]
+}
+
+type grpcLine struct {
+	text   string
+	cursor string
+}
+
+func NewGrpcTailer(rawURL string, requestTimeoutSec int, stateFilePath string) (*GrpcTailer, error) {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	service, method, _ := strings.Cut(strings.TrimPrefix(parsed.Path, "/"), "/")
+	if parsed.Host == "" || service == "" || method == "" || strings.Contains(method, "/") {
+		return nil, fmt.Errorf("gRPC URLs need a host and the method, e.g. grpc://host:port/logs.v1.LogStream/Tail")
+	}
+	target := *parsed
+	target.RawQuery, target.Fragment = "", ""
+	switch parsed.Scheme {
+	case "grpc":
+		target.Scheme = "http"
+	case "grpcs":
+		target.Scheme = "https"
+	default:
+		return nil, fmt.Errorf("invalid gRPC scheme: %s", parsed.Scheme)
+	}
+
+	timeout := time.Duration(requestTimeoutSec) * time.Second
+	tlsConfig := &tls.Config{}
+	dialer := &net.Dialer{Timeout: timeout}
+	transport := &http2.Transport{
+		TLSClientConfig: tlsConfig,
+		ReadIdleTimeout: 30 * time.Second, // pings find dead connections
+		PingTimeout:     timeout,
+	}
+	if target.Scheme == "http" {
+		// gRPC without TLS is HTTP/2 with prior knowledge.
+		transport.AllowHTTP = true
+		transport.DialTLSContext = func(ctx context.Context, network, addr string, _ *tls.Config) (net.Conn, error) {
+			return dialer.DialContext(ctx, network, addr)
+		}
+	} else {
+		transport.DialTLSContext = func(ctx context.Context, network, addr string, config *tls.Config) (net.Conn, error) {
+			tlsDialer := &tls.Dialer{NetDialer: dialer, Config: config}
+			return tlsDialer.DialContext(ctx, network, addr)
+		}
+	}
+	return &GrpcTailer{
+		TailerBase: TailerBase{
+			identity:      redactURL(rawURL),
+			stateFilePath: stateFilePath,
+			lastOffset:    0,
+		},
+		target:            target.String(),
+		requestTimeoutSec: requestTimeoutSec,
+		client:            &http.Client{Transport: transport},
+		tlsConfig:         tlsConfig,
+		fromStart:         true,
+	}, nil
+}
+
+// SetCACertFile makes grpcs connections verify the server with the CA
+// certificates in a PEM file instead of the system ones.
+func (t *GrpcTailer) SetCACertFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("could not read CA certificates: %v", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return fmt.Errorf("no CA certificates found in %s", path)
+	}
+	t.tlsConfig.RootCAs = pool
+	return nil
+}
+
+func (t *GrpcTailer) SetUserAgent(userAgent string) {
+	t.userAgent = userAgent
+}
+
+func (t *GrpcTailer) start(ctx context.Context) error {
+	request := appendProtoString(nil, 1, t.cursor)
+	request = appendProtoBool(request, 2, t.cursor == "" && t.fromStart)
+
+	// The stream outlives this call, so it gets its own context. Only waiting
+	// for the response headers is bounded by the request timeout.
+	streamCtx, cancel := context.WithCancel(context.Background())
+	timer := time.AfterFunc(time.Duration(t.requestTimeoutSec)*time.Second, cancel)
+	stopAbort := context.AfterFunc(ctx, cancel)
+
+	req, err := http.NewRequestWithContext(streamCtx, http.MethodPost, t.target, bytes.NewReader(grpcFrame(request)))
+	if err != nil {
+		cancel()
+		return err
+	}
+	req.Header.Set("Content-Type", "application/grpc+proto")
+	req.Header.Set("TE", "trailers")
+	if t.userAgent != "" {
+		req.Header.Set("User-Agent", t.userAgent)
+	}
+	resp, err := t.client.Do(req)
+	timer.Stop()
+	stopAbort()
+	if err != nil {
+		cancel()
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return classify(ErrConnect, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		closeBody(resp.Body)
+		cancel()
+		return statusError(resp)
+	}
+	// A call failing at once answers with the status in the headers.
+	if err := grpcStatus(resp.Header); err != nil {
+		closeBody(resp.Body)
+		cancel()
+		return err
+	}
+
+	t.body = resp.Body
+	t.cancel = cancel
+	t.receiver = receiveGrpc(resp, t.limitReader(streamCtx, resp.Body))
+	return nil
+}
+
+// receiveGrpc reads the lines of the stream in resp as they come.
+func receiveGrpc(resp *http.Response, body io.Reader) *receiver[grpcLine] {
+	return startReceiver(func() ([]grpcLine, error) {
+		message, err := readGrpcMessage(body)
+		if err == io.EOF {
+			// The trailers are there once the body was read to its end.
+			err = grpcStatus(resp.Trailer)
+			if err == nil && resp.Trailer.Get("Grpc-Status") == "" {
+				err = fmt.Errorf("gRPC stream ended without a status")
+			}
+			if err == nil {
+				err = io.EOF
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+		fields, err := parseProtoStrings(message)
+		if err != nil {
+			return nil, err
+		}
+		return []grpcLine{{text: strings.TrimSuffix(fields[1], "\n"), cursor: fields[2]}}, nil
+	})
+}
+
+// grpcStatus returns the error of a gRPC status in header, nil for OK or no
+// status.
+func grpcStatus(header http.Header) error {
+	value := header.Get("Grpc-Status")
+	if value == "" || value == "0" {
+		return nil
+	}
+	code, err := strconv.Atoi(value)
+	if err != nil {
+		return fmt.Errorf("invalid gRPC status: %q", value)
+	}
+	message, _ := url.PathUnescape(header.Get("Grpc-Message"))
+	return &GrpcStatusError{Code: code, Message: message}
+}
+
+func (t *GrpcTailer) stop() {
+	if t.cancel != nil {
+		t.cancel()
+		t.cancel = nil
+	}
+	if t.body != nil {
+		t.body.Close()
+		t.body = nil
+		<-t.receiver.done
+	}
+}
+
+func (t *GrpcTailer) Close() error {
+	t.stop()
+	t.client.CloseIdleConnections()
+	return nil
+}
+
+// SetPosition can only start over: at the oldest lines the server has for
+// offset 0 from the start, or at new lines for offset 0 from the end.
+func (t *GrpcTailer) SetPosition(ctx context.Context, offset int64, whence int) error {
+	if offset != 0 || whence != io.SeekStart && whence != io.SeekEnd {
+		return fmt.Errorf("can only start a gRPC stream at its start or end")
+	}
+	t.stop()
+	t.cursor = ""
+	t.fromStart = whence == io.SeekStart
+	t.lastOffset = 0
+	return nil
+}
+
+func (t *GrpcTailer) CheckPosition(ctx context.Context) error {
+	return nil
+}
+
+func (t *GrpcTailer) SetPositionLastLines(ctx context.Context, n int) error {
+	return fmt.Errorf("cannot start at the last lines of a gRPC stream")
+}
+
+func (t *GrpcTailer) SetPositionAtTime(ctx context.Context, since time.Time, layout string) error {
+	return fmt.Errorf("cannot search a gRPC stream by time")
+}
+
+func (t *GrpcTailer) Rewind(lines []Line) error {
+	return fmt.Errorf("cannot rewind a gRPC stream")
+}
+
+func (t *GrpcTailer) FetchNewLines(ctx context.Context) ([]Line, error) {
+	if t.body == nil {
+		err := t.start(ctx)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var lines []Line
+	closed, closeErr := t.receiver.take(func(received []grpcLine, closed bool) int {
+		for _, line := range received {
+			lines = append(lines, Line{Text: line.text, Offset: t.lastOffset})
+			t.lastOffset += int64(len(line.text)) + 1
+			if line.cursor != "" {
+				t.cursor = line.cursor
+			}
+		}
+		return len(received)
+	})
+
+	if closed {
+		t.stop()
+		var statusErr *GrpcStatusError
+		if errors.As(closeErr, &statusErr) {
+			return lines, closeErr // e.g. expired credentials, up to the retry policy
+		}
+		if closeErr != nil {
+			t.emitEvent(EventStreamClosed, "", "Stream failed: %v. Reconnecting.", closeErr)
+		} else {
+			t.emitEvent(EventStreamClosed, "", "Stream ended. Reconnecting.")
+		}
+	}
+	return lines, nil
+}
@@ -0,0 +1,150 @@
+package tailer
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// maxGrpcMessage bounds the size of a received message, like the 4 MiB
+// default of gRPC implementations.
+const maxGrpcMessage = 4 << 20
+
+// grpcFrame prefixes a message with the gRPC length-prefixed framing:
+// a compression flag, always 0 here, and the size as 4 bytes big-endian.
+func grpcFrame(message []byte) []byte {
+	frame := make([]byte, 5, 5+len(message))
+	binary.BigEndian.PutUint32(frame[1:], uint32(len(message)))
+	return append(frame, message...)
+}
+
+// readGrpcMessage reads the next length-prefixed message. It returns io.EOF
+// when the stream ends between messages.
+func readGrpcMessage(r io.Reader) ([]byte, error) {
+	var header [5]byte
+	_, err := io.ReadFull(r, header[:])
+	if err != nil {
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil, fmt.Errorf("gRPC stream ended within a message header")
+		}
+		return nil, err
+	}
+	if header[0] != 0 {
+		return nil, fmt.Errorf("received a compressed gRPC message, compression was not asked for")
+	}
+	size := binary.BigEndian.Uint32(header[1:])
+	if size > maxGrpcMessage {
+		return nil, fmt.Errorf("gRPC message of %d bytes is over the limit of %d", size, maxGrpcMessage)
+	}
+	message := make([]byte, size)
+	_, err = io.ReadFull(r, message)
+	if err != nil {
+		return nil, fmt.Errorf("gRPC stream ended within a message: %v", err)
+	}
+	return message, nil
+}
+
+// The protobuf wire format, as far as the messages of GrpcTailer need it.
+const (
+	protoVarint  = 0
+	protoFixed64 = 1
+	protoBytes   = 2
+	protoFixed32 = 5
+)
+
+func appendProtoString(b []byte, field int, s string) []byte {
+	if s == "" {
+		return b // the default value is not encoded
+	}
+	b = binary.AppendUvarint(b, uint64(field<<3|protoBytes))
+	b = binary.AppendUvarint(b, uint64(len(s)))
+	return append(b, s...)
+}
+
+func appendProtoBool(b []byte, field int, v bool) []byte {
+	if !v {
+		return b
+	}
+	b = binary.AppendUvarint(b, uint64(field<<3|protoVarint))
+	return append(b, 1)
+}
+
+// parseProtoStrings returns the string and bytes fields of a message by
+// field number, skipping fields of other types.
+func parseProtoStrings(b []byte) (map[int]string, error) {
+	fields := map[int]string{}
+	for len(b) > 0 {
+		key, n := binary.Uvarint(b)
+		if n <= 0 {
+			return nil, errInvalidProto
+		}
+		b = b[n:]
+		switch key & 7 {
+		case protoVarint:
+			_, n = binary.Uvarint(b)
+			if n <= 0 {
+				return nil, errInvalidProto
+			}
+			b = b[n:]
+		case protoFixed64:
+			if len(b) < 8 {
+				return nil, errInvalidProto
+			}
+			b = b[8:]
+		case protoBytes:
+			size, n := binary.Uvarint(b)
+			if n <= 0 || size > uint64(len(b)-n) {
+				return nil, errInvalidProto
+			}
+			fields[int(key>>3)] = string(b[n : n+int(size)])
+			b = b[n+int(size):]
+		case protoFixed32:
+			if len(b) < 4 {
+				return nil, errInvalidProto
+			}
+			b = b[4:]
+		default:
+			return nil, errInvalidProto
+		}
+	}
+	return fields, nil
+}
+
+var errInvalidProto = errors.New("invalid protobuf message")
+
+// GrpcStatusError is a call ended by the server with a gRPC status other than
+// OK.
+type GrpcStatusError struct {
+	Code    int
+	Message string
+}
+
+// gRPC status codes telling failures apart.
+const (
+	grpcNotFound         = 5
+	grpcPermissionDenied = 7
+	grpcUnavailable      = 14
+	grpcUnauthenticated  = 16
+)
+
+func (e *GrpcStatusError) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("gRPC status %d", e.Code)
+	}
+	return fmt.Sprintf("gRPC status %d: %s", e.Code, e.Message)
+}
+
+func (e *GrpcStatusError) Is(target error) bool {
+	switch target {
+	case ErrAuth:
+		return e.Code == grpcUnauthenticated || e.Code == grpcPermissionDenied
+	case ErrConnect:
+		return e.Code == grpcUnavailable
+	case os.ErrNotExist:
+		return e.Code == grpcNotFound
+	default:
+		return false
+	}
+}
@@ -0,0 +1,478 @@
+package tailer
+
+import (
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// HttpTailer polls a file over HTTP(S), using Range requests when supported.
+type HttpTailer struct {
+	TailerBase
+	httpConnector
+
+	rangeNotSupported bool
+	decompress        bool
+	acceptGzip        bool
+	chunkBytes        int64
+	etagOffset        int64 // offset reached when the ETag was stored, -1 if unknown
+	newlineOffset     int64 // offset reached by reading a newline, -1 if unknown
+	chunkSegments     int
+
+	multiRangeUnsupported bool
+}
+
+func NewHttpTailer(url string, requestTimeoutSec int, stateFilePath string) *HttpTailer {
+	return &HttpTailer{
+		TailerBase: TailerBase{
+			identity:      redactURL(url),
+			stateFilePath: stateFilePath,
+			lastOffset:    0,
+		},
+		httpConnector:     newHttpConnector(url, requestTimeoutSec),
+		rangeNotSupported: false,
+		etagOffset:        -1,
+		newlineOffset:     -1,
+	}
+}
+
+// SetDecompress makes the tailer decompress gzip, bzip2 and zstd files,
+// detected by Content-Type or extension. Range requests are not used then.
+func (t *HttpTailer) SetDecompress(decompress bool) {
+	t.decompress = decompress
+}
+
+// SetAcceptGzip asks the server to compress responses with gzip. Go only
+// does so itself for requests without Range, and offsets must count the
+// bytes of the file, so the tailer decompresses. Servers that compress
+// partial responses apply the range to the compressed data, which cannot be
+// followed; the tailer then stops asking for gzip.
+func (t *HttpTailer) SetAcceptGzip(acceptGzip bool) {
+	t.acceptGzip = acceptGzip
+}
+
+// SetChunkBytes bounds how much new data is read per poll, 0 means no limit. A
+// large backlog is then caught up with over several polls.
+func (t *HttpTailer) SetChunkBytes(chunkBytes int64) {
+	t.chunkBytes = chunkBytes
+}
+
+func (t *HttpTailer) SetPosition(ctx context.Context, offset int64, whence int) error {
+	return t.startWhenFound(ctx, func(ctx context.Context) error {
+		return t.setPosition(ctx, offset, whence)
+	})
+}
+
+func (t *HttpTailer) setPosition(ctx context.Context, offset int64, whence int) error {
+	switch whence {
+	case io.SeekStart:
+		t.lastOffset = offset
+	case io.SeekEnd:
+		if t.decompress {
+			return fmt.Errorf("cannot seek from the end when decompressing")
+		}
+		size, err := t.fetchSizeWithSuffixRange(ctx)
+		if err != nil {
+			return err
+		}
+		t.lastOffset = max(size+offset, 0)
+	default:
+		return fmt.Errorf("invalid whence: %d", whence)
+	}
+	return nil
+}
+
+func (t *HttpTailer) SetPositionLastLines(ctx context.Context, n int) error {
+	return t.startWhenFound(ctx, func(ctx context.Context) error {
+		return t.setPositionLastLines(ctx, n)
+	})
+}
+
+func (t *HttpTailer) setPositionLastLines(ctx context.Context, n int) error {
+	if t.decompress {
+		return fmt.Errorf("cannot start at the last lines when decompressing")
+	}
+	size, err := t.fetchSizeWithSuffixRange(ctx)
+	if err != nil {
+		return err
+	}
+	offset, err := findLastLines(size, func(offset int64, length int) ([]byte, error) {
+		return t.fetchRange(ctx, offset, length)
+	}, n)
+	if err != nil {
+		return err
+	}
+	t.lastOffset = offset
+	return nil
+}
+
+func (t *HttpTailer) CheckPosition(ctx context.Context) error {
+	if t.lastOffset == 0 || t.decompress {
+		return nil
+	}
+	size, err := t.fetchSizeWithSuffixRange(ctx)
+	if t.waitingFor(err) {
+		return nil // checked once the file exists
+	}
+	if err != nil {
+		return err
+	}
+	if t.lastOffset > size {
+		return t.truncated("", size, &t.lastOffset, "Saved offset %d is beyond the end of the file (%d bytes).", t.lastOffset, size)
+	}
+	return nil
+}
+
+// fetchSizeWithSuffixRange learns the file size from the Content-Range of a
+// request for its last byte. Servers without range support answer with the
+// whole file, then Content-Length is used without reading the body.
+func (t *HttpTailer) fetchSizeWithSuffixRange(ctx context.Context) (int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(t.requestTimeoutSec)*time.Second)
+	defer cancel()
+
+	req, err := t.newRequest(ctx, "GET", nil)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Range", "bytes=-1")
+
+	resp, err := t.do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer closeBody(resp.Body)
+
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+		_, _, size, err := parseContentRange(resp.Header.Get("Content-Range"))
+		if err != nil {
+			return 0, err
+		}
+		if size < 0 {
+			return 0, fmt.Errorf("server did not report file size")
+		}
+		return size, nil
+	case http.StatusRequestedRangeNotSatisfiable:
+		return 0, nil // empty file
+	case http.StatusOK:
+		if resp.ContentLength < 0 {
+			return 0, fmt.Errorf("server did not report file size")
+		}
+		return resp.ContentLength, nil
+	default:
+		return 0, statusError(resp)
+	}
+}
+
+func (t *HttpTailer) SetPositionAtTime(ctx context.Context, since time.Time, layout string) error {
+	return t.startWhenFound(ctx, func(ctx context.Context) error {
+		return t.setPositionAtTime(ctx, since, layout)
+	})
+}
+
+func (t *HttpTailer) setPositionAtTime(ctx context.Context, since time.Time, layout string) error {
+	if t.decompress {
+		return fmt.Errorf("cannot search by time when decompressing")
+	}
+	size, err := t.fetchSizeWithSuffixRange(ctx)
+	if err != nil {
+		return err
+	}
+	offset, err := findTimestamp(size, func(offset int64, length int) ([]byte, error) {
+		return t.fetchRange(ctx, offset, length)
+	}, since, layout)
+	if err != nil {
+		return err
+	}
+	t.lastOffset = offset
+	return nil
+}
+
+// fetchRange reads length bytes at offset with a single Range request.
+func (t *HttpTailer) fetchRange(ctx context.Context, offset int64, length int) ([]byte, error) {
+	if length <= 0 {
+		return nil, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(t.requestTimeoutSec)*time.Second)
+	defer cancel()
+
+	req, err := t.newRequest(ctx, "GET", nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", offset, offset+int64(length)-1))
+
+	resp, err := t.do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer closeBody(resp.Body)
+
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+		return io.ReadAll(io.LimitReader(resp.Body, int64(length)))
+	case http.StatusRequestedRangeNotSatisfiable:
+		return nil, nil
+	case http.StatusOK:
+		return nil, fmt.Errorf("server doesn't support range requests")
+	default:
+		return nil, statusError(resp)
+	}
+}
+
+func (t *HttpTailer) FetchNewLines(ctx context.Context) ([]Line, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	ready, err := t.fileReady(ctx)
+	if !ready {
+		return nil, err
+	}
+
+	req, err := t.newRequest(ctx, "GET", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	segments := t.segments()
+	if t.chunkBytes > 0 && !t.decompress {
+		req.Header.Set("Range", t.chunkRange())
+	} else if t.lastOffset > 0 && !t.decompress {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", t.lastOffset-1))
+	}
+	if t.acceptGzip {
+		req.Header.Set("Accept-Encoding", "gzip")
+	}
+	// A chunked read stops before the end of the file, so an unchanged file
+	// may still have unread data then.
+	if t.etag != "" && t.etagOffset == t.lastOffset && t.chunkBytes == 0 {
+		req.Header.Set("If-None-Match", t.etag)
+	}
+
+	resp, err := t.do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer closeBody(resp.Body)
+
+	if resp.StatusCode == http.StatusRequestedRangeNotSatisfiable {
+		if t.lastOffset == 0 {
+			return nil, nil // empty file, only asked with a chunk size
+		}
+		var size int64
+		if t.onTruncate == TruncateSkip {
+			size, err = t.fetchSizeWithSuffixRange(ctx)
+			if err != nil {
+				return nil, err
+			}
+		}
+		return nil, t.truncated("", size, &t.lastOffset, "Server returned 416, file was probably truncated.")
+	}
+
+	if resp.StatusCode == http.StatusNotModified {
+		t.emitEvent(EventNotModified, "", "")
+		return nil, nil
+	}
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusPartialContent {
+		err := statusError(resp)
+		if t.waitingFor(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	t.found()
+
+	gzipped := resp.Header.Get("Content-Encoding") == "gzip"
+	if gzipped && resp.StatusCode == http.StatusPartialContent {
+		t.acceptGzip = false
+		return nil, fmt.Errorf("server compressed a partial response, whose range is then of the compressed data; no longer asking for gzip")
+	}
+
+	size := int64(-1) // of the whole file, where known
+	boundary := ""
+	if resp.StatusCode == http.StatusPartialContent {
+		boundary = byteRangesBoundary(resp.Header.Get("Content-Type"))
+	}
+	if resp.StatusCode == http.StatusPartialContent && boundary == "" {
+		// Caches and CDNs may answer with 206 even to a request without Range,
+		// which is fine as long as the data starts where we asked.
+		start, _, total, err := parseContentRange(resp.Header.Get("Content-Range"))
+		if err != nil {
+			return nil, err
+		}
+		size = total
+		requested := max(t.lastOffset-1, 0)
+		if start != requested {
+			return nil, fmt.Errorf("requested range from %d, got range from %d", requested, start)
+		}
+	}
+
+	if t.decompress && resp.StatusCode == http.StatusOK {
+		compression := compressionFromContentType(resp.Header.Get("Content-Type"))
+		if compression == "" {
+			compression = compressionFromName(req.URL.Path)
+		}
+		if compression != "" {
+			reader, stopReading, err := t.contentReader(resp.Body, gzipped, cancel)
+			if err != nil {
+				return nil, err
+			}
+			defer stopReading()
+			return t.readCompressedLines(t.identity, t.limitReader(ctx, reader), compression, &t.lastOffset)
+		}
+	}
+
+	if segments > 1 && resp.StatusCode == http.StatusOK {
+		t.multiRangeUnsupported = true
+	}
+
+	var skipBytes int64 = 0
+	if t.lastOffset > 0 {
+		if resp.StatusCode == http.StatusPartialContent {
+			skipBytes = 1
+		} else {
+			if !t.rangeNotSupported && !t.decompress && segments == 1 {
+				t.emitEvent(EventRangeNotSupported, "", "Server doesn't support range requests.")
+				t.rangeNotSupported = true
+			}
+			skipBytes = t.lastOffset
+		}
+	}
+
+	// On a read error keep the complete lines received so far, so they don't
+	// have to be downloaded again.
+	reader, stopReading, err := t.contentReader(resp.Body, gzipped, cancel)
+	if err != nil {
+		return nil, err
+	}
+	defer stopReading()
+	var body []byte
+	var readErr error
+	if boundary != "" {
+		body, readErr = readByteRanges(t.limitReader(ctx, reader), boundary, max(t.lastOffset-1, 0))
+	} else {
+		if t.chunkBytes > 0 {
+			reader = io.LimitReader(reader, skipBytes+t.chunkBytes*int64(segments))
+		}
+		body, readErr = io.ReadAll(t.limitReader(ctx, reader))
+	}
+	if readErr == nil {
+		t.etag = resp.Header.Get("ETag")
+	}
+
+	// Without range support a shrunk file doesn't cause 416, only a shorter body.
+	if resp.StatusCode == http.StatusOK && readErr == nil && int64(len(body)) < skipBytes {
+		err := t.truncated("", int64(len(body)), &t.lastOffset, "File shrank below the last offset, it was probably truncated.")
+		if err != nil {
+			return nil, err
+		}
+		skipBytes = t.lastOffset
+	}
+
+	if len(body) == 0 {
+		if readErr != nil {
+			return nil, readErr
+		}
+		t.emitEvent(EventEmptyResponse, "", "Empty response.")
+		return nil, nil
+	}
+
+	if len(body) <= int(skipBytes) {
+		// fmt.Fprintf(os.Stderr, "No new bytes.\n")
+		return nil, readErr
+	}
+
+	// The byte before the offset is read again. Where it was a newline, it
+	// still has to be one, otherwise the file was replaced by another one at
+	// least as long.
+	if skipBytes > 0 && t.newlineOffset == t.lastOffset && body[skipBytes-1] != '\n' {
+		if resp.StatusCode == http.StatusOK && readErr == nil && t.chunkBytes == 0 {
+			size = int64(len(body))
+		}
+		if size < 0 && t.onTruncate == TruncateSkip {
+			size, err = t.fetchSizeWithSuffixRange(ctx)
+			if err != nil {
+				return nil, err
+			}
+		}
+		return nil, t.truncated("", max(size, 0), &t.lastOffset, "The byte before offset %d is no longer a newline, the file was probably replaced.", t.lastOffset)
+	}
+
+	if resp.StatusCode == http.StatusOK && readErr == nil && t.chunkBytes == 0 {
+		size = int64(len(body))
+	}
+	if size >= 0 {
+		modTime, _ := http.ParseTime(resp.Header.Get("Last-Modified"))
+		t.recordSize("", size, modTime)
+	}
+
+	body = body[skipBytes:]
+	lines, body := splitLines(body, &t.lastOffset)
+	if len(lines) > 0 {
+		t.newlineOffset = t.lastOffset
+	}
+
+	if len(lines) == 0 && t.chunkBytes > 0 && int64(len(body)) >= t.chunkBytes*int64(segments) {
+		return nil, fmt.Errorf("line at offset %d is longer than the chunk size of %d bytes", t.lastOffset, t.chunkBytes*int64(segments))
+	}
+	if readErr == nil {
+		t.etagOffset = t.lastOffset
+	}
+	return lines, readErr
+}
+
+// contentReader returns body read with the read timeout, decompressed when
+// the server gzipped it. The returned function must be called when done.
+func (t *HttpTailer) contentReader(body io.Reader, gzipped bool, cancel context.CancelFunc) (io.Reader, func(), error) {
+	reader, stopReading := t.bodyReader(body, cancel)
+	if !gzipped {
+		return reader, stopReading, nil
+	}
+	gz, err := gzip.NewReader(reader)
+	if err != nil {
+		stopReading()
+		return nil, nil, fmt.Errorf("invalid gzip response: %v", err)
+	}
+	return gz, stopReading, nil
+}
+
+// parseContentRange parses a Content-Range header like "bytes 100-199/1000".
+// The size is -1 when the server reports it as unknown ("*").
+func parseContentRange(contentRange string) (start int64, end int64, size int64, err error) {
+	invalid := fmt.Errorf("invalid Content-Range: %q", contentRange)
+	spec, ok := strings.CutPrefix(contentRange, "bytes ")
+	if !ok {
+		return 0, 0, 0, invalid
+	}
+	rangeStr, sizeStr, ok := strings.Cut(spec, "/")
+	if !ok {
+		return 0, 0, 0, invalid
+	}
+	startStr, endStr, ok := strings.Cut(rangeStr, "-")
+	if !ok {
+		return 0, 0, 0, invalid
+	}
+	start, err = strconv.ParseInt(startStr, 10, 64)
+	if err != nil || start < 0 {
+		return 0, 0, 0, invalid
+	}
+	end, err = strconv.ParseInt(endStr, 10, 64)
+	if err != nil || end < start {
+		return 0, 0, 0, invalid
+	}
+	size = -1
+	if sizeStr != "*" {
+		size, err = strconv.ParseInt(sizeStr, 10, 64)
+		if err != nil || size <= end {
+			return 0, 0, 0, invalid
+		}
+	}
+	return start, end, size, nil
+}
@@ -0,0 +1,327 @@
+package tailer
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fileServer serves one file whose content tests change between polls,
+// with range support like a static file server or without it.
+type fileServer struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	content  []byte
+	noRanges bool
+	ranges   []string // Range header of every request
+}
+
+func newFileServer(t *testing.T, content string) *fileServer {
+	s := &fileServer{content: []byte(content)}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serve))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *fileServer) serve(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	content, noRanges := bytes.Clone(s.content), s.noRanges
+	s.ranges = append(s.ranges, r.Header.Get("Range"))
+	s.mu.Unlock()
+	if noRanges {
+		w.Write(content)
+		return
+	}
+	http.ServeContent(w, r, "app.log", time.Time{}, bytes.NewReader(content))
+}
+
+func (s *fileServer) setContent(content string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.content = []byte(content)
+}
+
+func (s *fileServer) lastRange() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.ranges[len(s.ranges)-1]
+}
+
+func newTestHttpTailer(s *fileServer) *HttpTailer {
+	tailer := NewHttpTailer(s.URL+"/app.log", 1, "")
+	tailer.SetHTTPClient(s.Client())
+	return tailer
+}
+
+// poll fetches new lines and checks them against want and the offset after.
+func poll(t *testing.T, tailer Tailer, want []Line, wantOffset int64) {
+	t.Helper()
+	lines, err := tailer.FetchNewLines(context.Background())
+	if err != nil {
+		t.Fatalf("FetchNewLines: %v", err)
+	}
+	if len(lines) != 0 || len(want) != 0 {
+		if !slices.Equal(lines, want) {
+			t.Errorf("got lines %+v, want %+v", lines, want)
+		}
+	}
+	if tailer.Offset() != wantOffset {
+		t.Errorf("offset %d, want %d", tailer.Offset(), wantOffset)
+	}
+}
+
+func TestHttpFirstPollReadsWholeFile(t *testing.T) {
+	s := newFileServer(t, "one\ntwo\n")
+	tailer := newTestHttpTailer(s)
+
+	poll(t, tailer, []Line{{Text: "one", Offset: 0}, {Text: "two", Offset: 4}}, 8)
+	if got := s.lastRange(); got != "" {
+		t.Errorf("first request asked for range %q", got)
+	}
+}
+
+func TestHttpGrowingFile(t *testing.T) {
+	s := newFileServer(t, "one\n")
+	tailer := newTestHttpTailer(s)
+	poll(t, tailer, []Line{{Text: "one", Offset: 0}}, 4)
+
+	// The byte before the offset is asked for again, to tell a truncation.
+	s.setContent("one\ntwo\nthr")
+	poll(t, tailer, []Line{{Text: "two", Offset: 4}}, 8)
+	if got := s.lastRange(); got != "bytes=3-" {
+		t.Errorf("asked for range %q, want bytes=3-", got)
+	}
+
+	// The unterminated line waits for its newline.
+	poll(t, tailer, nil, 8)
+	s.setContent("one\ntwo\nthree\n")
+	poll(t, tailer, []Line{{Text: "three", Offset: 8}}, 14)
+	poll(t, tailer, nil, 14)
+}
+
+func TestHttpTruncatedFileIsReadAgain(t *testing.T) {
+	s := newFileServer(t, "one\ntwo\n")
+	tailer := newTestHttpTailer(s)
+	var events []Event
+	tailer.SetEventHandler(func(event Event) { events = append(events, event) })
+	poll(t, tailer, []Line{{Text: "one", Offset: 0}, {Text: "two", Offset: 4}}, 8)
+
+	// The range from offset 7 is past the end now: 416.
+	s.setContent("new\n")
+	poll(t, tailer, nil, 0)
+	if len(events) != 1 || events[0].Kind != EventTruncated {
+		t.Errorf("got events %+v, want one EventTruncated", events)
+	}
+	poll(t, tailer, []Line{{Text: "new", Offset: 0}}, 4)
+}
+
+func TestHttpTruncatedFileCanBeSkipped(t *testing.T) {
+	s := newFileServer(t, "one\ntwo\n")
+	tailer := newTestHttpTailer(s)
+	tailer.SetTruncatePolicy(TruncateSkip)
+	poll(t, tailer, []Line{{Text: "one", Offset: 0}, {Text: "two", Offset: 4}}, 8)
+
+	s.setContent("new\n")
+	poll(t, tailer, nil, 4)
+	s.setContent("new\nnewer\n")
+	poll(t, tailer, []Line{{Text: "newer", Offset: 4}}, 10)
+}
+
+func TestHttpServerWithoutRanges(t *testing.T) {
+	s := newFileServer(t, "one\n")
+	s.noRanges = true
+	tailer := newTestHttpTailer(s)
+	var events []Event
+	tailer.SetEventHandler(func(event Event) { events = append(events, event) })
+
+	poll(t, tailer, []Line{{Text: "one", Offset: 0}}, 4)
+	poll(t, tailer, nil, 4)
+	if len(events) != 1 || events[0].Kind != EventRangeNotSupported {
+		t.Errorf("got events %+v, want one EventRangeNotSupported", events)
+	}
+
+	// The whole file comes every time, the part already read is skipped.
+	s.setContent("one\ntwo\n")
+	poll(t, tailer, []Line{{Text: "two", Offset: 4}}, 8)
+	s.setContent("one\ntwo\nthree\n")
+	poll(t, tailer, []Line{{Text: "three", Offset: 8}}, 14)
+}
+
+func TestHttpChunkedReads(t *testing.T) {
+	s := newFileServer(t, "one\ntwo\nthree\n")
+	tailer := newTestHttpTailer(s)
+	tailer.SetChunkBytes(7)
+
+	poll(t, tailer, []Line{{Text: "one", Offset: 0}}, 4)
+	poll(t, tailer, []Line{{Text: "two", Offset: 4}}, 8)
+	poll(t, tailer, []Line{{Text: "three", Offset: 8}}, 14)
+	poll(t, tailer, nil, 14)
+}
+
+func TestHttpEmptyFile(t *testing.T) {
+	s := newFileServer(t, "")
+	tailer := newTestHttpTailer(s)
+
+	poll(t, tailer, nil, 0)
+	s.setContent("one\n")
+	poll(t, tailer, []Line{{Text: "one", Offset: 0}}, 4)
+}
+
+func TestHttpShrunkFileWithoutRanges(t *testing.T) {
+	s := newFileServer(t, "one\ntwo\n")
+	s.noRanges = true
+	tailer := newTestHttpTailer(s)
+	var events []Event
+	tailer.SetEventHandler(func(event Event) {
+		if event.Kind == EventTruncated {
+			events = append(events, event)
+		}
+	})
+	poll(t, tailer, []Line{{Text: "one", Offset: 0}, {Text: "two", Offset: 4}}, 8)
+
+	// Rotated: the body is now shorter than the offset, no 416 tells.
+	s.setContent("new\n")
+	poll(t, tailer, []Line{{Text: "new", Offset: 0}}, 4)
+	if len(events) != 1 {
+		t.Errorf("got %d EventTruncated, want 1", len(events))
+	}
+	poll(t, tailer, nil, 4)
+}
+
+func TestHttpSavedOffsetBeyondEnd(t *testing.T) {
+	for _, noRanges := range []bool{false, true} {
+		s := newFileServer(t, "one\ntwo\n")
+		s.noRanges = noRanges
+		tailer := newTestHttpTailer(s)
+		var events []Event
+		tailer.SetEventHandler(func(event Event) { events = append(events, event) })
+		if err := tailer.SetPosition(context.Background(), 100, io.SeekStart); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := tailer.CheckPosition(context.Background()); err != nil {
+			t.Fatalf("without ranges %v: CheckPosition: %v", noRanges, err)
+		}
+		if tailer.Offset() != 0 || len(events) != 1 || events[0].Kind != EventTruncated {
+			t.Errorf("without ranges %v: offset %d, events %+v", noRanges, tailer.Offset(), events)
+		}
+		poll(t, tailer, []Line{{Text: "one", Offset: 0}, {Text: "two", Offset: 4}}, 8)
+	}
+}
+
+func TestHttpConnectionIsReused(t *testing.T) {
+	// Without ranges the whole file comes every time, while a chunked read
+	// stops early: the rest has to be drained to keep the connection.
+	s := &fileServer{content: []byte("one\ntwo\nsix\nten\n" + strings.Repeat("more\n", 20000)), noRanges: true}
+	var mu sync.Mutex
+	conns := 0
+	s.Server = httptest.NewUnstartedServer(http.HandlerFunc(s.serve))
+	s.Config.ConnState = func(conn net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			mu.Lock()
+			conns++
+			mu.Unlock()
+		}
+	}
+	s.Start()
+	t.Cleanup(s.Close)
+
+	tailer := NewHttpTailer(s.URL+"/app.log", 1, "")
+	defer tailer.Close()
+	tailer.SetChunkBytes(5)
+	poll(t, tailer, []Line{{Text: "one", Offset: 0}}, 4)
+	poll(t, tailer, []Line{{Text: "two", Offset: 4}}, 8)
+	poll(t, tailer, []Line{{Text: "six", Offset: 8}}, 12)
+	poll(t, tailer, []Line{{Text: "ten", Offset: 12}}, 16)
+	mu.Lock()
+	defer mu.Unlock()
+	if conns != 1 {
+		t.Errorf("opened %d connections for 4 polls, want 1", conns)
+	}
+}
+
+func TestHttpOverlapByte(t *testing.T) {
+	t.Run("newline kept", func(t *testing.T) {
+		s := newFileServer(t, "ab\n")
+		tailer := newTestHttpTailer(s)
+		poll(t, tailer, []Line{{Text: "ab", Offset: 0}}, 3)
+		// The overlap byte is the newline read last, it is not output again
+		// and the next line keeps its first character.
+		s.setContent("ab\ncd\n")
+		poll(t, tailer, []Line{{Text: "cd", Offset: 3}}, 6)
+	})
+
+	t.Run("empty line after boundary", func(t *testing.T) {
+		s := newFileServer(t, "a\n")
+		tailer := newTestHttpTailer(s)
+		poll(t, tailer, []Line{{Text: "a", Offset: 0}}, 2)
+		s.setContent("a\n\nb\n")
+		poll(t, tailer, []Line{{Text: "", Offset: 2}, {Text: "b", Offset: 3}}, 5)
+	})
+
+	t.Run("newline replaced", func(t *testing.T) {
+		s := newFileServer(t, "ab\n")
+		tailer := newTestHttpTailer(s)
+		var events []Event
+		tailer.SetEventHandler(func(event Event) { events = append(events, event) })
+		poll(t, tailer, []Line{{Text: "ab", Offset: 0}}, 3)
+		// Another file at least as long: the byte before the offset is no
+		// longer the newline read last.
+		s.setContent("xyzw\n")
+		poll(t, tailer, nil, 0)
+		if len(events) != 1 || events[0].Kind != EventTruncated {
+			t.Errorf("got events %+v, want one EventTruncated", events)
+		}
+		poll(t, tailer, []Line{{Text: "xyzw", Offset: 0}}, 5)
+	})
+
+	t.Run("position mid-line", func(t *testing.T) {
+		s := newFileServer(t, "abcdef\n")
+		tailer := newTestHttpTailer(s)
+		if err := tailer.SetPosition(context.Background(), 2, io.SeekStart); err != nil {
+			t.Fatal(err)
+		}
+		// Nothing was read before the offset, any byte is fine there.
+		poll(t, tailer, []Line{{Text: "cdef", Offset: 2}}, 7)
+	})
+}
+
+// countingTransport counts the requests of a client.
+type countingTransport struct {
+	http.RoundTripper
+	mu       sync.Mutex
+	requests int
+}
+
+func (c *countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	c.mu.Lock()
+	c.requests++
+	c.mu.Unlock()
+	return c.RoundTripper.RoundTrip(req)
+}
+
+func TestHttpSuppliedClientIsUsed(t *testing.T) {
+	s := newFileServer(t, "one\n")
+	transport := &countingTransport{RoundTripper: s.Client().Transport}
+	tailer := NewHttpTailer(s.URL+"/app.log", 1, "")
+	tailer.SetHTTPClient(&http.Client{Transport: transport})
+
+	poll(t, tailer, []Line{{Text: "one", Offset: 0}}, 4)
+	if err := tailer.CheckPosition(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	transport.mu.Lock()
+	defer transport.mu.Unlock()
+	if transport.requests != 2 {
+		t.Errorf("supplied client made %d requests, want 2", transport.requests)
+	}
+}
@@ -0,0 +1,280 @@
+package tailer
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// httpConnector holds the HTTP client and request settings shared by the
+// tailers working over HTTP.
+type httpConnector struct {
+	url               string
+	requestTimeoutSec int
+	client            *http.Client
+	userAgent         string
+	requestIDHeader   string
+	digest            *digestAuth // set once the server asked for Digest authentication
+	readTimeout       time.Duration
+	forceHTTP2        bool
+	login             *formLogin
+}
+
+func newHttpConnector(url string, requestTimeoutSec int) httpConnector {
+	// Connecting and waiting for the response headers are bounded by the
+	// request timeout. Reading the body may take longer, as long as data
+	// keeps coming.
+	timeout := time.Duration(requestTimeoutSec) * time.Second
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = (&net.Dialer{Timeout: timeout}).DialContext
+	transport.TLSHandshakeTimeout = timeout
+	transport.ResponseHeaderTimeout = timeout
+	return httpConnector{
+		url:               url,
+		requestTimeoutSec: requestTimeoutSec,
+		client:            &http.Client{Transport: transport},
+		readTimeout:       timeout,
+	}
+}
+
+// SetHTTPClient makes requests go through client instead of one with the
+// request timeouts, e.g. to reach a test server. The Digest authentication
+// and the read timeout still apply.
+func (c *httpConnector) SetHTTPClient(client *http.Client) {
+	c.client = client
+}
+
+// SetHTTPVersion pins the protocol to "1.1" or "2". HTTP/2 needs an https
+// URL, a response over another protocol is an error then. By default the
+// protocol is negotiated.
+func (c *httpConnector) SetHTTPVersion(version string) error {
+	transport, ok := c.client.Transport.(*http.Transport)
+	if !ok && version != "" {
+		return fmt.Errorf("cannot pin the HTTP version of a custom client")
+	}
+	switch version {
+	case "":
+	case "1.1":
+		transport.ForceAttemptHTTP2 = false
+		transport.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
+	case "2":
+		if !strings.HasPrefix(c.url, "https:") {
+			return fmt.Errorf("HTTP/2 is only supported over https")
+		}
+		transport.ForceAttemptHTTP2 = true
+		c.forceHTTP2 = true
+	default:
+		return fmt.Errorf("invalid HTTP version: %s", version)
+	}
+	return nil
+}
+
+// SetIdleConns bounds the idle connections kept open for reuse by the next
+// poll, and how long they are kept. 0 keeps the defaults of net/http.
+func (c *httpConnector) SetIdleConns(maxIdle int, timeout time.Duration) error {
+	transport, ok := c.client.Transport.(*http.Transport)
+	if !ok {
+		if maxIdle == 0 && timeout == 0 {
+			return nil
+		}
+		return fmt.Errorf("cannot configure the idle connections of a custom client")
+	}
+	if maxIdle > 0 {
+		transport.MaxIdleConns = maxIdle
+		transport.MaxIdleConnsPerHost = maxIdle
+	}
+	if timeout > 0 {
+		transport.IdleConnTimeout = timeout
+	}
+	return nil
+}
+
+// maxDrainBytes is how much of an unread response body closeBody reads to
+// keep the connection. Beyond that, dropping the connection is cheaper.
+const maxDrainBytes = 256 << 10
+
+// closeBody reads what is left of body, so the connection can be reused even
+// when reading stopped early, e.g. at the chunk size, and closes it.
+func closeBody(body io.ReadCloser) {
+	io.CopyN(io.Discard, body, maxDrainBytes)
+	body.Close()
+}
+
+// SetReadTimeout sets how long a read of a response body may wait for data
+// before the download is abandoned, by default the request timeout.
+func (c *httpConnector) SetReadTimeout(d time.Duration) {
+	c.readTimeout = d
+}
+
+// idleTimeoutReader fails reads that get no data within timeout by calling
+// cancel, which must abort the request r belongs to.
+type idleTimeoutReader struct {
+	r       io.Reader
+	timeout time.Duration
+	timer   *time.Timer
+	expired atomic.Bool
+}
+
+// bodyReader returns body read with the read timeout. The returned function
+// must be called when done reading.
+func (c *httpConnector) bodyReader(body io.Reader, cancel context.CancelFunc) (io.Reader, func()) {
+	r := &idleTimeoutReader{r: body, timeout: c.readTimeout}
+	r.timer = time.AfterFunc(c.readTimeout, func() {
+		r.expired.Store(true)
+		cancel()
+	})
+	r.timer.Stop()
+	return r, func() { r.timer.Stop() }
+}
+
+func (r *idleTimeoutReader) Read(p []byte) (int, error) {
+	// Only time spent waiting for data counts, not e.g. rate limiting.
+	r.timer.Reset(r.timeout)
+	n, err := r.r.Read(p)
+	r.timer.Stop()
+	if err != nil && r.expired.Load() {
+		err = fmt.Errorf("no data received for %v", r.timeout)
+	}
+	return n, err
+}
+
+// SetUserAgent sets the User-Agent header sent with every request.
+func (c *httpConnector) SetUserAgent(userAgent string) {
+	c.userAgent = userAgent
+}
+
+// SetRequestIDHeader makes every request carry a random id in the given
+// header, so it can be found in server logs.
+func (c *httpConnector) SetRequestIDHeader(name string) {
+	c.requestIDHeader = name
+}
+
+func (c *httpConnector) newRequest(ctx context.Context, method string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, c.url, body)
+	if err != nil {
+		return nil, err
+	}
+	if c.userAgent != "" {
+		req.Header.Set("User-Agent", c.userAgent)
+	}
+	if c.requestIDHeader != "" {
+		req.Header.Set(c.requestIDHeader, newRequestID())
+	}
+	return req, nil
+}
+
+// do sends req, marking failures to reach the server with ErrConnect. With a
+// login form, it logs in first and again when the session has expired.
+func (c *httpConnector) do(req *http.Request) (*http.Response, error) {
+	if c.login == nil {
+		return c.doDigest(req)
+	}
+	if !c.login.loggedIn {
+		err := c.logIn(req.Context())
+		if err != nil {
+			return nil, err
+		}
+	}
+	resp, err := c.doDigest(req)
+	if err != nil || !c.loggedOut(resp) || (req.Body != nil && req.GetBody == nil) {
+		return resp, err
+	}
+	closeBody(resp.Body)
+	c.login.loggedIn = false
+	err = c.logIn(req.Context())
+	if err != nil {
+		return nil, err
+	}
+	retry, err := cloneRequest(req)
+	if err != nil {
+		return nil, err
+	}
+	return c.doDigest(retry)
+}
+
+// doDigest sends req. When the server asks for Digest authentication and
+// the URL has credentials, the request is sent again with them.
+func (c *httpConnector) doDigest(req *http.Request) (*http.Response, error) {
+	if c.digest != nil {
+		req.Header.Set("Authorization", c.digest.authorize(req))
+	}
+	resp, err := c.send(req)
+	if err != nil || resp.StatusCode != http.StatusUnauthorized || req.URL.User == nil {
+		return resp, err
+	}
+	challenge, ok := parseDigestChallenge(resp.Header.Values("WWW-Authenticate"))
+	if !ok || (req.Body != nil && req.GetBody == nil) {
+		return resp, nil
+	}
+	password, _ := req.URL.User.Password()
+	digest, err := newDigestAuth(req.URL.User.Username(), password, challenge)
+	if err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+	c.digest = digest
+
+	retry, err := cloneRequest(req)
+	if err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+	retry.Header.Set("Authorization", c.digest.authorize(retry))
+	closeBody(resp.Body)
+	return c.send(retry)
+}
+
+// cloneRequest returns a copy of req that can be sent again.
+func cloneRequest(req *http.Request) (*http.Request, error) {
+	retry := req.Clone(req.Context())
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		retry.Body = body
+	}
+	return retry, nil
+}
+
+func (c *httpConnector) send(req *http.Request) (*http.Response, error) {
+	resp, err := c.client.Do(req)
+	if err != nil && req.Context().Err() == nil {
+		return nil, classify(ErrConnect, err)
+	}
+	if err == nil && c.forceHTTP2 && resp.ProtoMajor != 2 {
+		resp.Body.Close()
+		return nil, fmt.Errorf("server answered over %s instead of HTTP/2", resp.Proto)
+	}
+	return resp, err
+}
+
+func (c *httpConnector) Close() error {
+	c.client.CloseIdleConnections()
+	return nil
+}
+
+func newRequestID() string {
+	id := make([]byte, 16)
+	rand.Read(id)
+	return hex.EncodeToString(id)
+}
+
+// redactURL drops the password from rawURL.
+func redactURL(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.User == nil {
+		return rawURL
+	}
+	u.User = url.User(u.User.Username())
+	return u.String()
+}
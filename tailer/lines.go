@@ -0,0 +1,27 @@
+package tailer
+
+import "bytes"
+
+// withSource sets the Source of lines.
+func withSource(lines []Line, source string) []Line {
+	for i := range lines {
+		lines[i].Source = source
+	}
+	return lines
+}
+
+// splitLines returns the complete lines at the start of data, without their
+// newlines, and the unterminated rest. data starts at offset, which is
+// advanced past every line returned.
+func splitLines(data []byte, offset *int64) ([]Line, []byte) {
+	lines := []Line{}
+	for {
+		i := bytes.IndexByte(data, '\n')
+		if i == -1 {
+			return lines, data
+		}
+		lines = append(lines, Line{Text: string(data[:i]), Offset: *offset})
+		*offset += int64(i + 1)
+		data = data[i+1:]
+	}
+}
@@ -0,0 +1,42 @@
+package tailer
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestSplitLines(t *testing.T) {
+	tests := []struct {
+		name       string
+		data       string
+		offset     int64
+		want       []Line
+		wantRest   string
+		wantOffset int64
+	}{
+		{name: "empty", data: "", offset: 0, want: []Line{}, wantRest: "", wantOffset: 0},
+		{name: "complete lines", data: "one\ntwo\n", offset: 0, want: []Line{{Text: "one", Offset: 0}, {Text: "two", Offset: 4}}, wantRest: "", wantOffset: 8},
+		{name: "no trailing newline", data: "one\ntwo", offset: 0, want: []Line{{Text: "one", Offset: 0}}, wantRest: "two", wantOffset: 4},
+		{name: "only a partial line", data: "one", offset: 10, want: []Line{}, wantRest: "one", wantOffset: 10},
+		{name: "empty lines", data: "\n\nx\n", offset: 0, want: []Line{{Text: "", Offset: 0}, {Text: "", Offset: 1}, {Text: "x", Offset: 2}}, wantRest: "", wantOffset: 4},
+		// Carriage returns are kept, -trim cr strips them from the output.
+		{name: "CRLF", data: "one\r\ntwo\r\n", offset: 0, want: []Line{{Text: "one\r", Offset: 0}, {Text: "two\r", Offset: 5}}, wantRest: "", wantOffset: 10},
+		{name: "CR at the end", data: "one\r", offset: 0, want: []Line{}, wantRest: "one\r", wantOffset: 0},
+		{name: "offset carried on", data: "a\nb", offset: 100, want: []Line{{Text: "a", Offset: 100}}, wantRest: "b", wantOffset: 102},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			offset := test.offset
+			lines, rest := splitLines([]byte(test.data), &offset)
+			if !slices.Equal(lines, test.want) {
+				t.Errorf("got lines %+v, want %+v", lines, test.want)
+			}
+			if string(rest) != test.wantRest {
+				t.Errorf("got rest %q, want %q", rest, test.wantRest)
+			}
+			if offset != test.wantOffset {
+				t.Errorf("got offset %d, want %d", offset, test.wantOffset)
+			}
+		})
+	}
+}
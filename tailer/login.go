@@ -0,0 +1,78 @@
+package tailer
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"strings"
+)
+
+// formLogin is a login form posted to get a session cookie. The cookie is
+// kept in memory only.
+type formLogin struct {
+	url      string
+	fields   url.Values
+	loggedIn bool
+}
+
+// SetLogin makes the tailer post fields as a form to loginURL before its
+// first request, and again whenever the session has expired: a response is
+// 401 or was redirected to the login page. The session cookie is kept in a
+// cookie jar in memory.
+func (c *httpConnector) SetLogin(loginURL string, fields url.Values) error {
+	parsed, err := url.Parse(loginURL)
+	if err != nil || parsed.Scheme != "http" && parsed.Scheme != "https" {
+		return fmt.Errorf("invalid login URL: %s", loginURL)
+	}
+	if c.client.Jar == nil {
+		jar, err := cookiejar.New(nil)
+		if err != nil {
+			return err
+		}
+		c.client.Jar = jar
+	}
+	c.login = &formLogin{url: loginURL, fields: fields}
+	return nil
+}
+
+func (c *httpConnector) logIn(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.login.url, strings.NewReader(c.login.fields.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if c.userAgent != "" {
+		req.Header.Set("User-Agent", c.userAgent)
+	}
+	resp, err := c.send(req)
+	if err != nil {
+		return fmt.Errorf("login failed: %w", err)
+	}
+	closeBody(resp.Body)
+	if resp.StatusCode >= 400 {
+		return classify(ErrAuth, fmt.Errorf("login failed: %w", statusError(resp)))
+	}
+	// Portals often answer a wrong password with the login page again, the
+	// missing cookie tells.
+	target, err := url.Parse(c.url)
+	if err == nil && len(c.client.Jar.Cookies(target)) == 0 {
+		return classify(ErrAuth, fmt.Errorf("login failed: no session cookie was set for %s", redactURL(c.url)))
+	}
+	c.login.loggedIn = true
+	return nil
+}
+
+// loggedOut tells whether resp shows that the session has expired.
+func (c *httpConnector) loggedOut(resp *http.Response) bool {
+	if resp.StatusCode == http.StatusUnauthorized {
+		return true
+	}
+	// Redirects were followed, resp.Request is the last one.
+	loginURL, err := url.Parse(c.login.url)
+	if err != nil {
+		return false
+	}
+	return resp.Request.URL.Host == loginURL.Host && resp.Request.URL.Path == loginURL.Path
+}
@@ -0,0 +1,99 @@
+package tailer
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"sort"
+	"strings"
+)
+
+// SetChunkSegments makes a chunked read ask for n consecutive chunks in one
+// request with several ranges, answered as multipart/byteranges, to catch up
+// with fewer round trips. Servers answering such requests with the whole file
+// get single ranges from then on. It applies only with SetChunkBytes.
+func (t *HttpTailer) SetChunkSegments(n int) {
+	t.chunkSegments = n
+}
+
+// segments returns how many chunks the next request asks for.
+func (t *HttpTailer) segments() int {
+	if t.chunkBytes == 0 || t.multiRangeUnsupported {
+		return 1
+	}
+	return max(t.chunkSegments, 1)
+}
+
+// chunkRange returns the Range header reading the next chunks, starting
+// with the byte before the offset.
+func (t *HttpTailer) chunkRange() string {
+	start := max(t.lastOffset-1, 0)
+	end := t.lastOffset + t.chunkBytes - 1
+	ranges := []string{fmt.Sprintf("%d-%d", start, end)}
+	for i := 1; i < t.segments(); i++ {
+		ranges = append(ranges, fmt.Sprintf("%d-%d", end+1, end+t.chunkBytes))
+		end += t.chunkBytes
+	}
+	return "bytes=" + strings.Join(ranges, ",")
+}
+
+// byteRangesBoundary returns the boundary of a multipart/byteranges
+// response, "" for other content types.
+func byteRangesBoundary(contentType string) string {
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil || mediaType != "multipart/byteranges" {
+		return ""
+	}
+	return params["boundary"]
+}
+
+type byteRange struct {
+	start int64
+	data  []byte
+}
+
+// readByteRanges reads the parts of a multipart/byteranges body and joins
+// them in offset order. They must start at start and leave no gaps. On a read
+// error it returns the data joined until then.
+func readByteRanges(body io.Reader, boundary string, start int64) ([]byte, error) {
+	var parts []byteRange
+	var readErr error
+	reader := multipart.NewReader(body, boundary)
+	for {
+		part, err := reader.NextPart()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			readErr = err
+			break
+		}
+		partStart, _, _, err := parseContentRange(part.Header.Get("Content-Range"))
+		if err != nil {
+			return nil, err
+		}
+		data, err := io.ReadAll(part)
+		parts = append(parts, byteRange{start: partStart, data: data})
+		if err != nil {
+			readErr = err
+			break
+		}
+	}
+
+	sort.Slice(parts, func(i, j int) bool { return parts[i].start < parts[j].start })
+	var joined []byte
+	next := start
+	for _, part := range parts {
+		if part.start != next {
+			if readErr != nil {
+				break // the missing part was not received
+			}
+			return nil, fmt.Errorf("requested ranges from %d, got a range from %d", next, part.start)
+		}
+		joined = append(joined, part.data...)
+		next += int64(len(part.data))
+	}
+	return joined, readErr
+}
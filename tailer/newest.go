@@ -0,0 +1,123 @@
+package tailer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+)
+
+// SetNewestOnly makes a tailer given a glob follow only the most recently
+// modified matching file, such as the current one of date-stamped logs. When
+// a newer file appears, the rest of the current one is read and the tailer
+// switches to the new one from its start. The current file is kept in the
+// state like the files of a glob.
+func (t *SftpTailer) SetNewestOnly(newestOnly bool) {
+	t.newestOnly = newestOnly
+}
+
+// newestMatch returns the most recently modified file matching the glob,
+// leaving out files followed before. Ties go to the name sorting last, which
+// for date-stamped names is the newest one. It returns "" without matches.
+func (t *SftpTailer) newestMatch() (string, error) {
+	matches, err := t.client.Glob(t.filePath)
+	if err != nil {
+		return "", fmt.Errorf("failed to glob %s: %v", t.filePath, err)
+	}
+	newest := ""
+	var newestInfo os.FileInfo
+	for _, path := range matches {
+		if t.finished[path] {
+			continue
+		}
+		info, err := t.client.Stat(path)
+		if errors.Is(err, os.ErrNotExist) {
+			continue // removed since the glob
+		}
+		if err != nil {
+			return "", fmt.Errorf("failed to stat %s: %v", path, err)
+		}
+		if newestInfo == nil || info.ModTime().After(newestInfo.ModTime()) ||
+			info.ModTime().Equal(newestInfo.ModTime()) && path > newest {
+			newest, newestInfo = path, info
+		}
+	}
+	return newest, nil
+}
+
+// readToEnd tells whether offset is at the end of path, as its size was last
+// seen by fetchFile. Compressed files are always read whole.
+func (t *SftpTailer) readToEnd(path string, offset int64) bool {
+	if t.compression(path) != "" {
+		return true
+	}
+	key := path
+	if !isGlobPattern(t.filePath) {
+		key = ""
+	}
+	s, ok := t.sizes[key]
+	return ok && offset >= s.size
+}
+
+// currentFile returns the file followed in newest-only mode, "" if none yet.
+func (t *SftpTailer) currentFile() string {
+	if len(t.offsets) == 0 {
+		return ""
+	}
+	// State saved while following the whole glob has several files, the one
+	// sorting last is kept.
+	paths := make([]string, 0, len(t.offsets))
+	for path := range t.offsets {
+		paths = append(paths, path)
+	}
+	return slices.Max(paths)
+}
+
+// fetchNewest tails the current file, switching to a newer one once the
+// current one was read to its end.
+func (t *SftpTailer) fetchNewest(ctx context.Context) ([]Line, error) {
+	newest, err := t.newestMatch()
+	if err != nil {
+		return nil, err
+	}
+	current := t.currentFile()
+	if current == "" {
+		if newest == "" {
+			return nil, nil
+		}
+		t.emitEvent(EventFileAdded, newest, "Following file %s.", newest)
+		current = newest
+	}
+
+	offset := t.offsets[current]
+	lines, err := t.fetchFile(ctx, current, &offset)
+	gone := errors.Is(err, os.ErrNotExist)
+	t.offsets = map[string]int64{current: offset}
+	if err != nil && !gone {
+		return lines, err
+	}
+	if newest == "" || newest == current {
+		if gone {
+			t.emitEvent(EventFileRemoved, current, "File %s disappeared.", current)
+			t.offsets = nil
+		}
+		return lines, nil
+	}
+	// With -chunk-bytes or an unterminated last line, reading the rest may
+	// take several polls.
+	if !gone && !t.readToEnd(current, offset) {
+		return lines, nil
+	}
+
+	t.emitEvent(EventFileSwitched, newest, "Switching from %s to newer file %s.", current, newest)
+	if t.finished == nil {
+		t.finished = map[string]bool{}
+	}
+	t.finished[current] = true
+	delete(t.partialSizes, current)
+	offset = 0
+	newLines, err := t.fetchFile(ctx, newest, &offset)
+	t.offsets = map[string]int64{newest: offset}
+	return append(lines, newLines...), err
+}
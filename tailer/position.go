@@ -0,0 +1,30 @@
+package tailer
+
+import "bytes"
+
+const lastLinesChunkSize = 64 * 1024
+
+// findLastLines returns the offset where the last n complete lines of a
+// source of the given size start. An incomplete line at the end is not
+// counted.
+func findLastLines(size int64, read readRangeFunc, n int) (int64, error) {
+	// The n complete lines start right after the (n+1)-th newline from the end.
+	remaining := n + 1
+	end := size
+	for end > 0 {
+		start := max(end-lastLinesChunkSize, 0)
+		chunk, err := read(start, int(end-start))
+		if err != nil {
+			return 0, err
+		}
+		chunk = chunk[:min(len(chunk), int(end-start))]
+		for i := bytes.LastIndexByte(chunk, '\n'); i != -1; i = bytes.LastIndexByte(chunk[:i], '\n') {
+			remaining--
+			if remaining == 0 {
+				return start + int64(i) + 1, nil
+			}
+		}
+		end = start
+	}
+	return 0, nil
+}
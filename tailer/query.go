@@ -0,0 +1,230 @@
+package tailer
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// queryMaxPages bounds how many pages a single poll follows.
+const queryMaxPages = 100
+
+// QueryTailer polls a log API that is queried with POST requests and pages
+// through results with a cursor. The request body is a template in which
+// {{cursor}} is replaced by the last cursor as a JSON string, or null on the
+// first request. Lines and the next cursor are picked from the JSON response
+// by paths like "data.logs[*].message" and "data.next_cursor".
+type QueryTailer struct {
+	TailerBase
+	httpConnector
+
+	bodyTemplate string
+	linesPath    string
+	cursorPath   string
+}
+
+func NewQueryTailer(url string, bodyTemplate string, linesPath string, cursorPath string, requestTimeoutSec int, stateFilePath string) *QueryTailer {
+	return &QueryTailer{
+		TailerBase: TailerBase{
+			identity:      redactURL(url) + " " + bodyTemplate,
+			stateFilePath: stateFilePath,
+			lastOffset:    0,
+		},
+		httpConnector: newHttpConnector(url, requestTimeoutSec),
+		bodyTemplate:  bodyTemplate,
+		linesPath:     linesPath,
+		cursorPath:    cursorPath,
+	}
+}
+
+func (t *QueryTailer) SetPosition(ctx context.Context, offset int64, whence int) error {
+	if whence != io.SeekStart || offset != 0 {
+		return fmt.Errorf("can only reset the position of a query")
+	}
+	t.cursor = ""
+	t.lastOffset = 0
+	return nil
+}
+
+func (t *QueryTailer) CheckPosition(ctx context.Context) error {
+	return nil
+}
+
+func (t *QueryTailer) SetPositionLastLines(ctx context.Context, n int) error {
+	return fmt.Errorf("cannot start at the last lines of a query")
+}
+
+func (t *QueryTailer) SetPositionAtTime(ctx context.Context, since time.Time, layout string) error {
+	return fmt.Errorf("cannot search a query by time")
+}
+
+func (t *QueryTailer) Rewind(lines []Line) error {
+	return fmt.Errorf("cannot rewind a query")
+}
+
+// FetchNewLines follows the cursor until a page comes back empty. The offset
+// counts the lines received.
+func (t *QueryTailer) FetchNewLines(ctx context.Context) ([]Line, error) {
+	lines := []Line{}
+	for range queryMaxPages {
+		pageLines, cursor, err := t.fetchPage(ctx)
+		if err != nil {
+			return lines, err
+		}
+		for _, text := range pageLines {
+			lines = append(lines, Line{Text: text, Offset: t.lastOffset})
+			t.lastOffset++
+		}
+		if len(pageLines) == 0 || cursor == "" || cursor == t.cursor {
+			if cursor != "" {
+				t.cursor = cursor
+			}
+			break
+		}
+		t.cursor = cursor
+	}
+	return lines, nil
+}
+
+func (t *QueryTailer) fetchPage(ctx context.Context) ([]string, string, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	cursorJSON := []byte("null")
+	if t.cursor != "" {
+		cursorJSON, _ = json.Marshal(t.cursor)
+	}
+	body := strings.ReplaceAll(t.bodyTemplate, "{{cursor}}", string(cursorJSON))
+
+	req, err := t.newRequest(ctx, "POST", strings.NewReader(body))
+	if err != nil {
+		return nil, "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := t.do(req)
+	if err != nil {
+		return nil, "", err
+	}
+	defer closeBody(resp.Body)
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", statusError(resp)
+	}
+
+	reader, stopReading := t.bodyReader(resp.Body, cancel)
+	defer stopReading()
+	respBody, err := io.ReadAll(t.limitReader(ctx, reader))
+	if err != nil {
+		return nil, "", err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(respBody))
+	decoder.UseNumber()
+	var doc any
+	err = decoder.Decode(&doc)
+	if err != nil {
+		return nil, "", fmt.Errorf("invalid JSON response: %v", err)
+	}
+
+	values, err := selectJSONPath(doc, t.linesPath)
+	if err != nil {
+		return nil, "", err
+	}
+	lines := make([]string, 0, len(values))
+	for _, value := range values {
+		lines = append(lines, jsonValueString(value))
+	}
+
+	cursor := ""
+	if t.cursorPath != "" {
+		values, err := selectJSONPath(doc, t.cursorPath)
+		if err != nil {
+			return nil, "", err
+		}
+		if len(values) > 0 && values[0] != nil {
+			cursor = jsonValueString(values[0])
+		}
+	}
+	return lines, cursor, nil
+}
+
+// selectJSONPath returns the values found at path, which is a dot-separated
+// list of object keys, each optionally followed by [N] to index an array or
+// [*] to take all its elements. Missing keys yield no values.
+func selectJSONPath(doc any, path string) ([]any, error) {
+	values := []any{doc}
+	if path == "" {
+		return values, nil
+	}
+	for _, part := range strings.Split(path, ".") {
+		key, indexes, _ := strings.Cut(part, "[")
+		if key != "" {
+			values = selectKey(values, key)
+		}
+		for indexes != "" {
+			index, rest, ok := strings.Cut(indexes, "]")
+			if !ok {
+				return nil, fmt.Errorf("invalid path %q", path)
+			}
+			var err error
+			values, err = selectIndex(values, index)
+			if err != nil {
+				return nil, fmt.Errorf("invalid path %q: %v", path, err)
+			}
+			indexes = strings.TrimPrefix(rest, "[")
+		}
+	}
+	return values, nil
+}
+
+func selectKey(values []any, key string) []any {
+	selected := []any{}
+	for _, value := range values {
+		if object, ok := value.(map[string]any); ok {
+			if child, ok := object[key]; ok {
+				selected = append(selected, child)
+			}
+		}
+	}
+	return selected
+}
+
+func selectIndex(values []any, index string) ([]any, error) {
+	selected := []any{}
+	for _, value := range values {
+		array, ok := value.([]any)
+		if !ok {
+			continue
+		}
+		if index == "*" || index == "" {
+			selected = append(selected, array...)
+			continue
+		}
+		i, err := strconv.Atoi(index)
+		if err != nil {
+			return nil, fmt.Errorf("invalid index %q", index)
+		}
+		if i < 0 {
+			i += len(array)
+		}
+		if i >= 0 && i < len(array) {
+			selected = append(selected, array[i])
+		}
+	}
+	return selected, nil
+}
+
+// jsonValueString returns strings as they are and anything else as JSON.
+func jsonValueString(value any) string {
+	if s, ok := value.(string); ok {
+		return s
+	}
+	data, _ := json.Marshal(value)
+	return string(data)
+}
@@ -0,0 +1,46 @@
+package tailer
+
+import (
+	"context"
+	"io"
+
+	"golang.org/x/time/rate"
+)
+
+// SetRateLimit throttles reading new data to bytesPerSec, 0 removes the limit.
+func (t *TailerBase) SetRateLimit(bytesPerSec int) {
+	if bytesPerSec <= 0 {
+		t.limiter = nil
+		return
+	}
+	t.limiter = rate.NewLimiter(rate.Limit(bytesPerSec), bytesPerSec)
+}
+
+// limitReader wraps r so reading from it respects the rate limit.
+func (t *TailerBase) limitReader(ctx context.Context, r io.Reader) io.Reader {
+	if t.limiter == nil {
+		return r
+	}
+	return &rateLimitedReader{ctx: ctx, r: r, limiter: t.limiter}
+}
+
+type rateLimitedReader struct {
+	ctx     context.Context
+	r       io.Reader
+	limiter *rate.Limiter
+}
+
+func (r *rateLimitedReader) Read(p []byte) (int, error) {
+	// Never read more than one burst, WaitN can't wait for more.
+	if len(p) > r.limiter.Burst() {
+		p = p[:r.limiter.Burst()]
+	}
+	n, err := r.r.Read(p)
+	if n > 0 {
+		waitErr := r.limiter.WaitN(r.ctx, n)
+		if waitErr != nil && err == nil {
+			err = waitErr
+		}
+	}
+	return n, err
+}
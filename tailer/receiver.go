@@ -0,0 +1,91 @@
+package tailer
+
+import (
+	"io"
+	"sync"
+	"time"
+)
+
+// receiver reads a connection in the background for the tailers that keep
+// one open, holding what arrived until the next poll takes it.
+type receiver[T any] struct {
+	done chan struct{} // closed when reading stops
+
+	mu       sync.Mutex
+	pending  []T // received but not taken yet
+	lastData time.Time
+	closed   bool
+	closeErr error // nil when the connection ended cleanly
+}
+
+// startReceiver calls read until it fails, io.EOF meaning a clean end.
+func startReceiver[T any](read func() ([]T, error)) *receiver[T] {
+	r := &receiver[T]{done: make(chan struct{}), lastData: time.Now()}
+	go r.run(read)
+	return r
+}
+
+// receiveBytes reads body as it comes.
+func receiveBytes(body io.Reader) *receiver[byte] {
+	buf := make([]byte, 32*1024)
+	return startReceiver(func() ([]byte, error) {
+		n, err := body.Read(buf)
+		return buf[:n], err
+	})
+}
+
+func (r *receiver[T]) run(read func() ([]T, error)) {
+	defer close(r.done)
+	for {
+		items, err := read()
+		r.mu.Lock()
+		r.pending = append(r.pending, items...)
+		if len(items) > 0 {
+			r.lastData = time.Now()
+		}
+		if err != nil {
+			r.closed = true
+			if err != io.EOF {
+				r.closeErr = err
+			}
+		}
+		r.mu.Unlock()
+		if err != nil {
+			return
+		}
+	}
+}
+
+// take passes what was received to consume, which returns how many items it
+// used; the rest is kept for the next call. closed is passed too, taken
+// before the items, so once it is true nothing more will come.
+func (r *receiver[T]) take(consume func(pending []T, closed bool) int) (closed bool, closeErr error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	used := consume(r.pending, r.closed)
+	r.pending = append(r.pending[:0], r.pending[used:]...)
+	return r.closed, r.closeErr
+}
+
+// idle returns how long nothing was received.
+func (r *receiver[T]) idle() time.Duration {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return time.Since(r.lastData)
+}
+
+// takeLines returns the complete lines received, and once the connection
+// closed also its unterminated last line, which won't be finished anymore.
+func takeLines(r *receiver[byte], offset *int64) (lines []Line, closed bool, closeErr error) {
+	closed, closeErr = r.take(func(pending []byte, closed bool) int {
+		var rest []byte
+		lines, rest = splitLines(pending, offset)
+		if closed && len(rest) > 0 {
+			lines = append(lines, Line{Text: string(rest), Offset: *offset})
+			*offset += int64(len(rest))
+			rest = nil
+		}
+		return len(pending) - len(rest)
+	})
+	return lines, closed, closeErr
+}
@@ -0,0 +1,65 @@
+package tailer
+
+import (
+	"errors"
+	"io"
+	"testing"
+	"time"
+)
+
+// waitReceived waits until r holds n bytes.
+func waitReceived(t *testing.T, r *receiver[byte], n int) {
+	t.Helper()
+	for deadline := time.Now().Add(time.Second); ; time.Sleep(time.Millisecond) {
+		r.mu.Lock()
+		received := len(r.pending)
+		r.mu.Unlock()
+		if received >= n {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("received %d bytes, want %d", received, n)
+		}
+	}
+}
+
+func TestTakeLinesKeepsPartialLineUntilClose(t *testing.T) {
+	reader, writer := io.Pipe()
+	r := receiveBytes(reader)
+	var offset int64
+
+	writer.Write([]byte("one\ntw"))
+	waitReceived(t, r, 6)
+	lines, closed, _ := takeLines(r, &offset)
+	if closed || len(lines) != 1 || lines[0].Text != "one" || offset != 4 {
+		t.Fatalf("got %+v, closed %v, offset %d", lines, closed, offset)
+	}
+
+	writer.Write([]byte("o\nthree"))
+	writer.Close()
+	<-r.done
+	lines, closed, closeErr := takeLines(r, &offset)
+	if !closed || closeErr != nil {
+		t.Fatalf("got closed %v, error %v", closed, closeErr)
+	}
+	if len(lines) != 2 || lines[0] != (Line{Text: "two", Offset: 4}) || lines[1] != (Line{Text: "three", Offset: 8}) {
+		t.Errorf("got %+v", lines)
+	}
+	if offset != 13 {
+		t.Errorf("offset %d, want 13", offset)
+	}
+}
+
+func TestTakeLinesReportsFailure(t *testing.T) {
+	reader, writer := io.Pipe()
+	r := receiveBytes(reader)
+	failure := errors.New("connection reset")
+	writer.CloseWithError(failure)
+	<-r.done
+
+	var offset int64
+	_, closed, closeErr := takeLines(r, &offset)
+	if !closed || !errors.Is(closeErr, failure) {
+		t.Errorf("got closed %v, error %v", closed, closeErr)
+	}
+}
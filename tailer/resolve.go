@@ -0,0 +1,43 @@
+package tailer
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"time"
+
+	"golang.org/x/net/proxy"
+)
+
+// resolvingDialer connects to overridden addresses for some host:port pairs,
+// like curl's --resolve.
+type resolvingDialer struct {
+	dialer proxy.ContextDialer
+	hosts  map[string]string // "host:port" to "addr:port"
+}
+
+func (d resolvingDialer) DialContext(ctx context.Context, network string, address string) (net.Conn, error) {
+	if override, ok := d.hosts[address]; ok {
+		address = override
+	}
+	return d.dialer.DialContext(ctx, network, address)
+}
+
+// SetResolve makes connections to the "host:port" keys of hosts go to the
+// "addr:port" values instead. TLS still verifies the original host name.
+// A custom client set with SetHTTPClient is left as is.
+func (c *httpConnector) SetResolve(hosts map[string]string) {
+	transport, ok := c.client.Transport.(*http.Transport)
+	if !ok {
+		return
+	}
+	dialer := &net.Dialer{Timeout: time.Duration(c.requestTimeoutSec) * time.Second}
+	transport.DialContext = resolvingDialer{dialer: dialer, hosts: hosts}.DialContext
+}
+
+// SetResolve makes connections to the "host:port" keys of hosts go to the
+// "addr:port" values instead. The host key is still checked for the original
+// host. Call it after SetProxy or SetUnixSocket.
+func (c *sshConnector) SetResolve(hosts map[string]string) {
+	c.dialer = resolvingDialer{dialer: c.dialer, hosts: hosts}
+}
@@ -0,0 +1,609 @@
+package tailer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+)
+
+// SftpTailer follows a file, or every file matching a glob, over SFTP. On
+// servers without the SFTP subsystem it falls back to shell commands.
+type SftpTailer struct {
+	TailerBase
+	sshConnector
+
+	filePath   string
+	client     remoteFS
+	noSftp     bool // the server has no SFTP subsystem, files are read with shell commands
+	sshClient  *ssh.Client
+	decompress bool
+	chunkBytes int64
+	idleClose  time.Duration
+	lastData   time.Time // when the connection was opened or last returned lines
+	newestOnly bool
+	followLink bool
+	finished   map[string]bool // files left for a newer one in newest-only mode
+
+	// partialSizes remembers the file size when a poll ended in a line without
+	// its newline. If the size is the same on the next poll, the writer is
+	// assumed done and the line is returned as is.
+	partialSizes map[string]int64
+	flushPartial bool // return such a line at once
+}
+
+func NewSftpTailer(address string, username string, password string, filePath string, requestTimeoutSec int, stateFilePath string) *SftpTailer {
+	return &SftpTailer{
+		TailerBase: TailerBase{
+			identity:      fmt.Sprintf("sftp://%s@%s/%s", username, address, filePath),
+			stateFilePath: stateFilePath,
+			lastOffset:    0,
+		},
+		sshConnector: newSshConnector(address, username, password, requestTimeoutSec),
+		filePath:     filePath,
+	}
+}
+
+// SetDecompress makes the tailer decompress files ending with .gz, .bz2 and
+// .zst. They are read whole, again only when their size or modification time
+// changed.
+func (t *SftpTailer) SetDecompress(decompress bool) {
+	t.decompress = decompress
+}
+
+// SetChunkBytes bounds how much new data is read from each file per poll, 0
+// means no limit.
+func (t *SftpTailer) SetChunkBytes(chunkBytes int64) {
+	t.chunkBytes = chunkBytes
+}
+
+// SetFlushPartialLine makes the tailer return a line without its newline at
+// the end of a file at once, rather than on the next poll once the file
+// stopped growing. For reading a file once.
+func (t *SftpTailer) SetFlushPartialLine(flush bool) {
+	t.flushPartial = flush
+}
+
+// SetIdleClose makes the tailer close its connection once no new data came
+// for d, and open a new one on the next poll. Firewalls may drop idle
+// connections without telling, which makes the next poll hang. 0 keeps the
+// connection.
+func (t *SftpTailer) SetIdleClose(d time.Duration) {
+	t.idleClose = d
+}
+
+// compression tells how path is to be decompressed, "" if not at all. When
+// following a glob, compressed files are always decompressed: they are
+// typically rotated logs, whose raw bytes are of no use. Their offsets count
+// decompressed bytes.
+func (t *SftpTailer) compression(path string) string {
+	if !t.decompress && !isGlobPattern(t.filePath) {
+		return ""
+	}
+	return compressionFromName(path)
+}
+
+func (t *SftpTailer) connect(ctx context.Context) error {
+	sshClient, err := t.dialSsh(ctx)
+	if err != nil {
+		return err
+	}
+
+	// The operations on files are bounded one by one, like starting the SFTP
+	// session, which is not context aware either.
+	timeout := time.Duration(t.requestTimeoutSec) * time.Second
+	abort := func() { sshClient.Close() }
+	if !t.noSftp {
+		timer := time.AfterFunc(timeout, abort)
+		sftpClient, err := sftp.NewClient(sshClient)
+		if !timer.Stop() {
+			if err == nil {
+				sftpClient.Close()
+			}
+			err = classify(ErrConnect, fmt.Errorf("starting the SFTP session timed out"))
+		}
+		if err == nil {
+			t.sshClient = sshClient
+			t.client = newDeadlineFS(sftpFS{sftpClient}, timeout, abort)
+			t.lastData = time.Now()
+			return nil
+		}
+		if !isSftpUnavailable(err) {
+			sshClient.Close()
+			return err
+		}
+		t.noSftp = true
+		t.emitEvent(EventSftpUnavailable, "", "SFTP is not available on %s, reading files through shell commands.", t.address)
+	}
+
+	t.sshClient = sshClient
+	t.client = newDeadlineFS(newShellFS(sshClient), timeout, abort)
+	t.lastData = time.Now()
+	return nil
+}
+
+func (t *SftpTailer) disconnect() {
+	if t.client != nil {
+		t.client.Close()
+		t.client = nil
+	}
+	if t.sshClient != nil {
+		t.sshClient.Close()
+		t.sshClient = nil
+	}
+}
+
+func (t *SftpTailer) Close() error {
+	t.disconnect()
+	return nil
+}
+
+// abortOnDone closes the current connection when ctx is done, which makes any
+// blocked SFTP operation return. The returned function disarms it. A server
+// that stops answering is dealt with by the deadline of every operation.
+func (t *SftpTailer) abortOnDone(ctx context.Context) func() bool {
+	sshClient := t.sshClient
+	return context.AfterFunc(ctx, func() { sshClient.Close() })
+}
+
+func (t *SftpTailer) SetPosition(ctx context.Context, offset int64, whence int) error {
+	t.linkTarget = "" // the position is in the file the path points to
+	return t.startWhenFound(ctx, func(ctx context.Context) error {
+		return t.setPosition(ctx, offset, whence)
+	})
+}
+
+func (t *SftpTailer) setPosition(ctx context.Context, offset int64, whence int) error {
+	if whence != io.SeekStart && whence != io.SeekEnd {
+		return fmt.Errorf("invalid whence: %d", whence)
+	}
+	t.compressed = nil
+	if t.client == nil {
+		err := t.connect(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to connect: %w", err)
+		}
+	}
+	stop := t.abortOnDone(ctx)
+	defer stop()
+
+	var err error
+	if isGlobPattern(t.filePath) {
+		err = t.seekGlob(offset, whence)
+	} else {
+		err = t.seekFile(t.filePath, &t.lastOffset, offset, whence)
+	}
+	// A missing file leaves the connection usable.
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		t.disconnect()
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+	}
+	return err
+}
+
+func (t *SftpTailer) seekGlob(offset int64, whence int) error {
+	if whence == io.SeekStart && offset != 0 {
+		return fmt.Errorf("cannot seek to an absolute offset when following multiple files")
+	}
+
+	var matches []string
+	if t.newestOnly {
+		newest, err := t.newestMatch()
+		if err != nil {
+			return err
+		}
+		if newest != "" {
+			matches = []string{newest}
+		}
+	} else {
+		var err error
+		matches, err = t.client.Glob(t.filePath)
+		if err != nil {
+			return fmt.Errorf("failed to glob %s: %v", t.filePath, err)
+		}
+	}
+
+	offsets := make(map[string]int64, len(matches))
+	for _, path := range matches {
+		var fileOffset int64
+		err := t.seekFile(path, &fileOffset, offset, whence)
+		if err != nil {
+			return err
+		}
+		offsets[path] = fileOffset
+	}
+	t.offsets = offsets
+	return nil
+}
+
+func (t *SftpTailer) seekFile(path string, fileOffset *int64, offset int64, whence int) error {
+	stat, err := t.client.Stat(path)
+	if err != nil {
+		return fmt.Errorf("failed to stat %s: %w", path, err)
+	}
+
+	if whence == io.SeekEnd {
+		if t.compression(path) != "" {
+			return fmt.Errorf("cannot seek from the end of compressed file %s", path)
+		}
+		offset += stat.Size()
+	}
+	if offset > stat.Size() {
+		t.emitEvent(EventOffsetClamped, path, "Offset %d is beyond the end of %s, starting at %d.", offset, path, stat.Size())
+		offset = stat.Size()
+	}
+	*fileOffset = max(offset, 0)
+	return nil
+}
+
+func (t *SftpTailer) CheckPosition(ctx context.Context) error {
+	if t.lastOffset == 0 && len(t.offsets) == 0 {
+		return nil
+	}
+	if t.client == nil {
+		err := t.connect(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to connect: %w", err)
+		}
+	}
+	stop := t.abortOnDone(ctx)
+	defer stop()
+
+	var err error
+	if isGlobPattern(t.filePath) {
+		for path, offset := range t.offsets {
+			err = t.checkFile(path, &offset)
+			if err != nil {
+				break
+			}
+			t.offsets[path] = offset
+		}
+	} else if t.followLink && t.linkTarget != "" {
+		err = t.checkFile(t.linkTarget, &t.lastOffset)
+	} else {
+		err = t.checkFile(t.filePath, &t.lastOffset)
+	}
+	if err != nil {
+		t.disconnect()
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+	}
+	return err
+}
+
+func (t *SftpTailer) checkFile(path string, offset *int64) error {
+	if *offset == 0 || t.compression(path) != "" {
+		return nil // compressed files are checked when read
+	}
+	stat, err := t.client.Stat(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil // dealt with by FetchNewLines
+	}
+	if err != nil {
+		return fmt.Errorf("failed to stat %s: %v", path, err)
+	}
+	if *offset > stat.Size() {
+		return t.truncated(path, stat.Size(), offset, "Saved offset %d is beyond the end of %s (%d bytes).", *offset, path, stat.Size())
+	}
+	return nil
+}
+
+func (t *SftpTailer) SetPositionLastLines(ctx context.Context, n int) error {
+	if isGlobPattern(t.filePath) {
+		return fmt.Errorf("cannot start at the last lines when following multiple files")
+	}
+	if t.compression(t.filePath) != "" {
+		return fmt.Errorf("cannot start at the last lines of a compressed file")
+	}
+	t.linkTarget = ""
+	return t.startWhenFound(ctx, func(ctx context.Context) error {
+		return t.withFile(ctx, func(file remoteFile, size int64) error {
+			offset, err := findLastLines(size, readAtFunc(file), n)
+			if err != nil {
+				return err
+			}
+			t.lastOffset = offset
+			return nil
+		})
+	})
+}
+
+func (t *SftpTailer) SetPositionAtTime(ctx context.Context, since time.Time, layout string) error {
+	if isGlobPattern(t.filePath) {
+		return fmt.Errorf("cannot search by time when following multiple files")
+	}
+	if t.compression(t.filePath) != "" {
+		return fmt.Errorf("cannot search by time in a compressed file")
+	}
+	t.linkTarget = ""
+	return t.startWhenFound(ctx, func(ctx context.Context) error {
+		return t.withFile(ctx, func(file remoteFile, size int64) error {
+			offset, err := findTimestamp(size, readAtFunc(file), since, layout)
+			if err != nil {
+				return err
+			}
+			t.lastOffset = offset
+			return nil
+		})
+	})
+}
+
+// withFile opens the followed file for random access, connecting if needed.
+func (t *SftpTailer) withFile(ctx context.Context, f func(file remoteFile, size int64) error) error {
+	if t.client == nil {
+		err := t.connect(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to connect: %w", err)
+		}
+	}
+	stop := t.abortOnDone(ctx)
+	defer stop()
+
+	err := t.openAndRun(f)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		t.disconnect()
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+	}
+	return err
+}
+
+func (t *SftpTailer) openAndRun(f func(file remoteFile, size int64) error) error {
+	file, err := t.client.Open(t.filePath)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", t.filePath, err)
+	}
+	defer file.Close()
+
+	stat, err := file.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to stat %s: %v", t.filePath, err)
+	}
+	return f(file, stat.Size())
+}
+
+func readAtFunc(file io.ReaderAt) readRangeFunc {
+	return func(offset int64, length int) ([]byte, error) {
+		buf := make([]byte, length)
+		n, err := file.ReadAt(buf, offset)
+		if err == io.EOF {
+			err = nil
+		}
+		return buf[:n], err
+	}
+}
+
+// Rewind moves every file back to the first of lines read from it. In
+// newest-only mode and with a followed symlink, a switch to another file
+// made while reading lines is undone.
+func (t *SftpTailer) Rewind(lines []Line) error {
+	if len(lines) == 0 {
+		return nil
+	}
+	first := map[string]int64{} // offset of the first line from each file
+	for _, line := range lines {
+		if _, ok := first[line.Source]; !ok {
+			first[line.Source] = line.Offset
+			t.rewindCompressed(line.Source, line.Offset)
+		}
+	}
+	switch {
+	case isGlobPattern(t.filePath) && t.newestOnly:
+		delete(t.finished, lines[0].Source)
+		t.offsets = map[string]int64{lines[0].Source: lines[0].Offset}
+	case isGlobPattern(t.filePath):
+		if t.offsets == nil {
+			t.offsets = map[string]int64{}
+		}
+		maps.Copy(t.offsets, first)
+	case t.followLink:
+		t.linkTarget, t.lastOffset = lines[0].Source, lines[0].Offset
+	default:
+		return t.TailerBase.Rewind(lines)
+	}
+	return nil
+}
+
+func isGlobPattern(path string) bool {
+	return strings.ContainsAny(path, "*?[")
+}
+
+func (t *SftpTailer) FetchNewLines(ctx context.Context) ([]Line, error) {
+	if t.client != nil && t.idleClose > 0 && time.Since(t.lastData) >= t.idleClose {
+		t.disconnect()
+	}
+	if t.client == nil {
+		err := t.connect(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to connect: %w", err)
+		}
+	}
+	stop := t.abortOnDone(ctx)
+	defer stop()
+
+	ready, err := t.fileReady(ctx)
+	if !ready {
+		return nil, err
+	}
+
+	var lines []Line
+	switch {
+	case isGlobPattern(t.filePath) && t.newestOnly:
+		lines, err = t.fetchNewest(ctx)
+	case isGlobPattern(t.filePath):
+		lines, err = t.fetchGlob(ctx)
+	case t.followLink:
+		lines, err = t.fetchSymlink(ctx)
+		if t.waitingFor(err) {
+			return nil, nil
+		}
+	default:
+		lines, err = t.fetchFile(ctx, t.filePath, &t.lastOffset)
+		if t.waitingFor(err) {
+			return nil, nil // the connection is fine, keep it
+		}
+	}
+	if err != nil {
+		t.disconnect()
+		if ctx.Err() != nil {
+			return lines, ctx.Err()
+		}
+		return lines, err
+	}
+	t.found()
+	if len(lines) > 0 {
+		t.lastData = time.Now()
+	}
+	t.pruneCompressed()
+	return lines, nil
+}
+
+// fetchGlob tails every file matching t.filePath. On error it returns the
+// lines read until then, their offsets are already committed.
+func (t *SftpTailer) fetchGlob(ctx context.Context) ([]Line, error) {
+	matches, err := t.client.Glob(t.filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to glob %s: %v", t.filePath, err)
+	}
+
+	if t.offsets == nil {
+		t.offsets = map[string]int64{}
+	}
+	matched := make(map[string]bool, len(matches))
+	lines := []Line{}
+	for _, path := range matches {
+		matched[path] = true
+		offset, ok := t.offsets[path]
+		if !ok {
+			t.emitEvent(EventFileAdded, path, "Following new file %s.", path)
+		}
+		fileLines, err := t.fetchFile(ctx, path, &offset)
+		if errors.Is(err, os.ErrNotExist) {
+			delete(t.offsets, path) // removed since the glob
+			continue
+		}
+		t.offsets[path] = offset
+		lines = append(lines, fileLines...)
+		if err != nil {
+			return lines, err
+		}
+	}
+
+	// Glob ignores I/O errors, so a file missing from matches may just be
+	// unreachable. Forget it only once the server confirms it is gone.
+	for path := range t.offsets {
+		if matched[path] {
+			continue
+		}
+		_, err := t.client.Lstat(path)
+		if err == nil {
+			continue
+		}
+		if !errors.Is(err, os.ErrNotExist) {
+			return lines, fmt.Errorf("failed to stat %s: %v", path, err)
+		}
+		t.emitEvent(EventFileRemoved, path, "File %s disappeared.", path)
+		delete(t.offsets, path)
+		delete(t.partialSizes, path)
+	}
+
+	return lines, nil
+}
+
+func (t *SftpTailer) fetchFile(ctx context.Context, path string, offset *int64) ([]Line, error) {
+	file, err := t.client.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	defer file.Close()
+
+	stat, err := file.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat %s: %v", path, err)
+	}
+
+	if compression := t.compression(path); compression != "" {
+		stamp := fileStamp{size: stat.Size(), modTime: stat.ModTime().UnixNano()}
+		read := t.readBefore(path, stamp)
+		*offset = read.offset
+		if read.complete {
+			return nil, nil
+		}
+		lines, err := t.readCompressedLines(path, t.limitReader(ctx, file), compression, offset)
+		t.markRead(path, stamp, *offset, err == nil)
+		return withSource(lines, path), err
+	}
+
+	if isGlobPattern(t.filePath) {
+		t.recordSize(path, stat.Size(), stat.ModTime())
+	} else {
+		t.recordSize("", stat.Size(), stat.ModTime())
+	}
+
+	if stat.Size() < *offset {
+		err := t.truncated(path, stat.Size(), offset, "File %s truncated.", path)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if stat.Size() == *offset {
+		return nil, nil
+	}
+
+	_, err = file.Seek(*offset, io.SeekStart)
+	if err != nil {
+		return nil, fmt.Errorf("failed to seek %s to %v: %v", path, *offset, err)
+	}
+
+	// On a read error keep the complete lines received so far.
+	var reader io.Reader = file
+	if t.chunkBytes > 0 {
+		reader = io.LimitReader(reader, t.chunkBytes)
+	}
+	body, err := io.ReadAll(t.limitReader(ctx, reader))
+	if err != nil {
+		err = fmt.Errorf("failed to read %s from %v: %v", path, *offset, err)
+	}
+
+	lines, body := splitLines(body, offset)
+
+	if len(lines) == 0 && t.chunkBytes > 0 && int64(len(body)) >= t.chunkBytes {
+		return nil, fmt.Errorf("line at offset %d of %s is longer than the chunk size of %d bytes", *offset, path, t.chunkBytes)
+	}
+	if err == nil && *offset+int64(len(body)) >= stat.Size() {
+		lines = append(lines, t.takeFinalLine(path, body, stat.Size(), offset)...)
+	}
+	return withSource(lines, path), err
+}
+
+// takeFinalLine returns the unterminated line at the end of a file, once the
+// file stopped growing since the previous poll.
+func (t *SftpTailer) takeFinalLine(path string, partial []byte, size int64, offset *int64) []Line {
+	if len(partial) == 0 {
+		delete(t.partialSizes, path)
+		return nil
+	}
+	if previous, ok := t.partialSizes[path]; !t.flushPartial && (!ok || previous != size) {
+		if t.partialSizes == nil {
+			t.partialSizes = map[string]int64{}
+		}
+		t.partialSizes[path] = size
+		return nil
+	}
+	delete(t.partialSizes, path)
+	line := Line{Text: string(partial), Offset: *offset}
+	*offset += int64(len(partial))
+	return []Line{line}
+}
@@ -0,0 +1,84 @@
+package tailer
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// readAll polls tailer until a poll returns nothing, like -no-follow.
+func readAll(t *testing.T, tailer Tailer) string {
+	t.Helper()
+	var all []Line
+	for {
+		lines, err := tailer.FetchNewLines(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(lines) == 0 {
+			return texts(all)
+		}
+		all = append(all, lines...)
+	}
+}
+
+func TestChunkedReadReachesPartialLine(t *testing.T) {
+	files := &memFS{files: map[string]memFileInfo{
+		"/logs/app.log": {data: []byte("ab\ncd\nef"), modTime: time.Unix(1700000000, 0)},
+	}}
+	tailer := NewSftpTailer("host:22", "user", "", "/logs/app.log", 1, "")
+	tailer.client = files
+	tailer.SetChunkBytes(4)
+	tailer.SetFlushPartialLine(true)
+
+	if got := readAll(t, tailer); got != "ab\ncd\nef\n" {
+		t.Errorf("got %q", got)
+	}
+	if tailer.Offset() != 8 {
+		t.Errorf("offset %d, want 8", tailer.Offset())
+	}
+}
+
+func TestPartialLineWaitsForNextPoll(t *testing.T) {
+	files := &memFS{files: map[string]memFileInfo{
+		"/logs/app.log": {data: []byte("ab\ncd"), modTime: time.Unix(1700000000, 0)},
+	}}
+	tailer := NewSftpTailer("host:22", "user", "", "/logs/app.log", 1, "")
+	tailer.client = files
+
+	lines, err := tailer.FetchNewLines(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := texts(lines); got != "ab\n" {
+		t.Fatalf("first poll got %q", got)
+	}
+	lines, err = tailer.FetchNewLines(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := texts(lines); got != "cd\n" {
+		t.Errorf("second poll got %q", got)
+	}
+}
+
+func TestSftpSavedOffsetBeyondEnd(t *testing.T) {
+	files := &memFS{files: map[string]memFileInfo{
+		"/logs/app.log": {data: []byte("one\ntwo\n"), modTime: time.Unix(1700000000, 0)},
+	}}
+	tailer := NewSftpTailer("host:22", "user", "", "/logs/app.log", 1, "")
+	tailer.client = files
+	var events []Event
+	tailer.SetEventHandler(func(event Event) { events = append(events, event) })
+	tailer.lastOffset = 100 // as loaded from a state file
+
+	if err := tailer.CheckPosition(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if tailer.Offset() != 0 || len(events) != 1 || events[0].Kind != EventTruncated {
+		t.Errorf("offset %d, events %+v", tailer.Offset(), events)
+	}
+	if got := readAll(t, tailer); got != "one\ntwo\n" {
+		t.Errorf("got %q", got)
+	}
+}
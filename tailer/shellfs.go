@@ -0,0 +1,276 @@
+package tailer
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+)
+
+// remoteFS is the file access SftpTailer needs. It is served by the SFTP
+// subsystem, or by shell commands on servers that don't enable it.
+type remoteFS interface {
+	Glob(pattern string) ([]string, error)
+	Stat(path string) (os.FileInfo, error)
+	Lstat(path string) (os.FileInfo, error)
+	ReadLink(path string) (string, error)
+	Open(path string) (remoteFile, error)
+	Close() error
+}
+
+type remoteFile interface {
+	io.ReadSeekCloser
+	io.ReaderAt
+	Stat() (os.FileInfo, error)
+}
+
+type sftpFS struct {
+	*sftp.Client
+}
+
+func (c sftpFS) Open(path string) (remoteFile, error) {
+	file, err := c.Client.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	return file, nil
+}
+
+// isSftpUnavailable tells whether err means the server has no SFTP subsystem.
+func isSftpUnavailable(err error) bool {
+	return strings.Contains(err.Error(), "subsystem request failed")
+}
+
+// shellFS reads files by running stat, tail and head in SSH sessions. Each
+// operation is a command, so it is slower than SFTP.
+type shellFS struct {
+	client *ssh.Client
+}
+
+func newShellFS(client *ssh.Client) *shellFS {
+	return &shellFS{client: client}
+}
+
+func (c *shellFS) Close() error {
+	return nil // the SSH connection belongs to the tailer
+}
+
+// output runs command and returns its standard output. A command failing
+// because a file is missing yields an error matching os.ErrNotExist.
+func (c *shellFS) output(command string) ([]byte, error) {
+	session, err := c.client.NewSession()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open session: %v", err)
+	}
+	defer session.Close()
+	var stderr tailBuffer
+	session.Stderr = &stderr
+	out, err := session.Output(command)
+	if err != nil {
+		message := strings.TrimSpace(string(stderr.Bytes()))
+		if strings.Contains(message, "No such file") {
+			return nil, fmt.Errorf("%s: %w", message, os.ErrNotExist)
+		}
+		return nil, fmt.Errorf("%q failed: %v: %s", command, err, message)
+	}
+	return out, nil
+}
+
+func (c *shellFS) Glob(pattern string) ([]string, error) {
+	out, err := c.output(fmt.Sprintf(`for f in %s; do [ -e "$f" ] && printf '%%s\n' "$f"; done; true`, quoteGlob(pattern)))
+	if err != nil {
+		return nil, err
+	}
+	var matches []string
+	for _, line := range strings.Split(string(out), "\n") {
+		if line != "" {
+			matches = append(matches, line)
+		}
+	}
+	return matches, nil
+}
+
+func (c *shellFS) Stat(path string) (os.FileInfo, error) {
+	return c.stat("stat -L -c '%s %Y %f' -- "+QuoteShell(path), path)
+}
+
+func (c *shellFS) Lstat(path string) (os.FileInfo, error) {
+	return c.stat("stat -c '%s %Y %f' -- "+QuoteShell(path), path)
+}
+
+func (c *shellFS) ReadLink(path string) (string, error) {
+	out, err := c.output("readlink -- " + QuoteShell(path))
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(string(out), "\n"), nil
+}
+
+func (c *shellFS) stat(command string, name string) (os.FileInfo, error) {
+	out, err := c.output(command)
+	if err != nil {
+		return nil, err
+	}
+	fields := strings.Fields(string(out))
+	if len(fields) != 3 {
+		return nil, fmt.Errorf("unexpected stat output for %s: %q", name, out)
+	}
+	sizeField, mtimeField, modeField := fields[0], fields[1], fields[2]
+	size, err := strconv.ParseInt(sizeField, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("unexpected size of %s: %q", name, out)
+	}
+	mtime, err := strconv.ParseInt(mtimeField, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("unexpected modification time of %s: %q", name, out)
+	}
+	// The raw mode is in hex, only telling symlinks apart is needed.
+	rawMode, err := strconv.ParseUint(modeField, 16, 32)
+	if err != nil {
+		return nil, fmt.Errorf("unexpected mode of %s: %q", name, out)
+	}
+	var mode fs.FileMode
+	if rawMode&0xf000 == 0xa000 { // S_IFMT, S_IFLNK
+		mode = fs.ModeSymlink
+	}
+	return shellFileInfo{name: path.Base(name), size: size, modTime: time.Unix(mtime, 0), mode: mode}, nil
+}
+
+func (c *shellFS) Open(path string) (remoteFile, error) {
+	info, err := c.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	return &shellFile{fs: c, path: path, info: info}, nil
+}
+
+// shellFile reads a file from its offset to the end with tail. Its size is
+// the one when it was opened.
+type shellFile struct {
+	fs      *shellFS
+	path    string
+	info    os.FileInfo
+	offset  int64
+	session *ssh.Session
+	stdout  io.Reader
+	stderr  tailBuffer
+}
+
+func (f *shellFile) Stat() (os.FileInfo, error) {
+	return f.info, nil
+}
+
+func (f *shellFile) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += f.offset
+	case io.SeekEnd:
+		offset += f.info.Size()
+	default:
+		return 0, fmt.Errorf("invalid whence: %d", whence)
+	}
+	if offset < 0 {
+		return 0, fmt.Errorf("negative offset: %d", offset)
+	}
+	f.stop()
+	f.offset = offset
+	return offset, nil
+}
+
+func (f *shellFile) Read(p []byte) (int, error) {
+	if f.session == nil {
+		session, err := f.fs.client.NewSession()
+		if err != nil {
+			return 0, fmt.Errorf("failed to open session: %v", err)
+		}
+		stdout, err := session.StdoutPipe()
+		if err != nil {
+			session.Close()
+			return 0, err
+		}
+		f.stderr.Reset()
+		session.Stderr = &f.stderr
+		err = session.Start(fmt.Sprintf("tail -c +%d -- %s", f.offset+1, QuoteShell(f.path)))
+		if err != nil {
+			session.Close()
+			return 0, err
+		}
+		f.session, f.stdout = session, stdout
+	}
+	n, err := f.stdout.Read(p)
+	f.offset += int64(n)
+	if err == io.EOF {
+		if waitErr := f.session.Wait(); waitErr != nil {
+			err = fmt.Errorf("tail failed: %v: %s", waitErr, bytes.TrimSpace(f.stderr.Bytes()))
+		}
+	}
+	return n, err
+}
+
+func (f *shellFile) ReadAt(p []byte, offset int64) (int, error) {
+	out, err := f.fs.output(fmt.Sprintf("tail -c +%d -- %s | head -c %d", offset+1, QuoteShell(f.path), len(p)))
+	n := copy(p, out)
+	if err == nil && n < len(p) {
+		err = io.EOF
+	}
+	return n, err
+}
+
+func (f *shellFile) stop() {
+	if f.session != nil {
+		f.session.Close()
+		f.session, f.stdout = nil, nil
+	}
+}
+
+func (f *shellFile) Close() error {
+	f.stop()
+	return nil
+}
+
+type shellFileInfo struct {
+	name    string
+	size    int64
+	modTime time.Time
+	mode    fs.FileMode
+}
+
+func (i shellFileInfo) Name() string       { return i.name }
+func (i shellFileInfo) Size() int64        { return i.size }
+func (i shellFileInfo) Mode() fs.FileMode  { return i.mode }
+func (i shellFileInfo) ModTime() time.Time { return i.modTime }
+func (i shellFileInfo) IsDir() bool        { return false }
+func (i shellFileInfo) Sys() any           { return nil }
+
+// QuoteShell quotes s as a single word for a POSIX shell.
+func QuoteShell(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// quoteGlob escapes pattern for the shell, leaving the characters that make
+// it a glob. [^...] is turned into the POSIX [!...].
+func quoteGlob(pattern string) string {
+	var b strings.Builder
+	for i, r := range pattern {
+		switch {
+		case r == '^' && i > 0 && pattern[i-1] == '[':
+			b.WriteByte('!')
+		case strings.ContainsRune("*?[]/._-", r),
+			'a' <= r && r <= 'z', 'A' <= r && r <= 'Z', '0' <= r && r <= '9':
+			b.WriteRune(r)
+		default:
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,135 @@
+package tailer
+
+import (
+	"bytes"
+	"strings"
+	"time"
+)
+
+const sinceChunkSize = 4096
+
+// readRangeFunc reads up to length bytes at offset, fewer only at EOF.
+type readRangeFunc func(offset int64, length int) ([]byte, error)
+
+// lineReader reads whole lines from a random-access source of known size.
+type lineReader struct {
+	size   int64
+	read   readRangeFunc
+	layout string
+}
+
+// lineAt finds the first line starting at or after offset. It returns the
+// line's start, the start of the following line and its content without
+// the newline.
+func (r *lineReader) lineAt(offset int64) (int64, int64, []byte, error) {
+	start := offset
+	if offset > 0 {
+		// offset is a line start only if the byte before it is a newline.
+		nl, err := r.indexNewline(offset - 1)
+		if err != nil {
+			return 0, 0, nil, err
+		}
+		start = nl + 1
+	}
+	if start >= r.size {
+		return r.size, r.size, nil, nil
+	}
+
+	nl, err := r.indexNewline(start)
+	if err != nil {
+		return 0, 0, nil, err
+	}
+	end := nl + 1
+	line, err := r.read(start, int(min(nl, r.size)-start))
+	if err != nil {
+		return 0, 0, nil, err
+	}
+	return start, min(end, r.size), line, nil
+}
+
+// indexNewline returns the offset of the first newline at or after offset,
+// or size if there is none.
+func (r *lineReader) indexNewline(offset int64) (int64, error) {
+	for offset < r.size {
+		chunk, err := r.read(offset, sinceChunkSize)
+		if err != nil {
+			return 0, err
+		}
+		if len(chunk) == 0 {
+			break
+		}
+		if i := bytes.IndexByte(chunk, '\n'); i != -1 {
+			return offset + int64(i), nil
+		}
+		offset += int64(len(chunk))
+	}
+	return r.size, nil
+}
+
+// timestampAt finds the first line at or after offset that starts with a
+// timestamp. Lines without one are skipped, they usually continue the
+// previous record.
+func (r *lineReader) timestampAt(offset int64) (int64, int64, time.Time, error) {
+	for {
+		start, end, line, err := r.lineAt(offset)
+		if err != nil || start >= r.size {
+			return start, end, time.Time{}, err
+		}
+		if ts, ok := r.parseTimestamp(string(line)); ok {
+			return start, end, ts, nil
+		}
+		offset = end
+	}
+}
+
+func (r *lineReader) parseTimestamp(line string) (time.Time, bool) {
+	return ParseLineTimestamp(line, r.layout)
+}
+
+// ParseLineTimestamp parses the leading timestamp of a line, taking as many
+// space-separated fields as the layout has.
+func ParseLineTimestamp(line string, layout string) (time.Time, bool) {
+	nFields := strings.Count(layout, " ") + 1
+	fields := strings.SplitN(line, " ", nFields+1)
+	if len(fields) < nFields {
+		return time.Time{}, false
+	}
+	ts, err := time.Parse(layout, strings.Join(fields[:nFields], " "))
+	return ts, err == nil
+}
+
+// findTimestamp binary-searches a source for the first line whose leading
+// timestamp is at or after since. It assumes timestamps never decrease
+// through the file. The returned offset is size when there is no such line.
+func findTimestamp(size int64, read readRangeFunc, since time.Time, layout string) (int64, error) {
+	r := &lineReader{size: size, read: read, layout: layout}
+
+	// The answer is always in [lo, hi], lo is a line start and all timestamped
+	// lines before it are older than since.
+	lo, hi := int64(0), size
+	for lo < hi {
+		mid := lo + (hi-lo)/2
+		start, end, ts, err := r.timestampAt(mid)
+		if err != nil {
+			return 0, err
+		}
+		if start >= hi {
+			// No timestamped line begins in [mid, hi), look at [lo, mid).
+			start, end, ts, err = r.timestampAt(lo)
+			if err != nil {
+				return 0, err
+			}
+			if start >= hi || !ts.Before(since) {
+				return min(start, hi), nil
+			}
+			lo = end
+			continue
+		}
+		if ts.Before(since) {
+			lo = end
+		} else {
+			hi = start
+		}
+	}
+	return lo, nil
+}
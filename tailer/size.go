@@ -0,0 +1,39 @@
+package tailer
+
+import "time"
+
+type fileSize struct {
+	size    int64
+	modTime time.Time
+}
+
+// recordSize remembers the size of a followed file as last seen. path is ""
+// for the file whose position is lastOffset.
+func (t *TailerBase) recordSize(path string, size int64, modTime time.Time) {
+	if t.sizes == nil {
+		t.sizes = map[string]fileSize{}
+	}
+	t.sizes[path] = fileSize{size: size, modTime: modTime}
+}
+
+// Size returns the size of the followed files as last seen, to compare with
+// Offset, and the latest modification time, zero if unknown. ok is false
+// for sources without a known size, like streams and compressed files.
+func (t *TailerBase) Size() (size int64, modTime time.Time, ok bool) {
+	add := func(path string) {
+		s, found := t.sizes[path]
+		if !found {
+			return
+		}
+		size += s.size
+		if s.modTime.After(modTime) {
+			modTime = s.modTime
+		}
+		ok = true
+	}
+	add("")
+	for path := range t.offsets {
+		add(path)
+	}
+	return size, modTime, ok
+}
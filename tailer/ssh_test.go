@@ -0,0 +1,162 @@
+package tailer
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// silentListener accepts connections and never answers, like a host whose
+// SSH server hangs.
+func silentListener(t *testing.T) net.Listener {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		var conns []net.Conn
+		defer func() {
+			for _, conn := range conns {
+				conn.Close()
+			}
+		}()
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			conns = append(conns, conn)
+		}
+	}()
+	return ln
+}
+
+func TestHandshakeIsBounded(t *testing.T) {
+	ln := silentListener(t)
+	c := newSshConnector(ln.Addr().String(), "user", "password", 1)
+	c.SetInsecureIgnoreHostKey()
+
+	start := time.Now()
+	_, err := c.dialSsh(context.Background())
+	if !errors.Is(err, ErrConnect) {
+		t.Fatalf("got error %v, want ErrConnect", err)
+	}
+	if elapsed := time.Since(start); elapsed > 3*time.Second {
+		t.Errorf("handshake took %v with a timeout of 1s", elapsed)
+	}
+}
+
+type countingDialer struct {
+	dials int
+}
+
+func (d *countingDialer) DialContext(ctx context.Context, network string, address string) (net.Conn, error) {
+	d.dials++
+	return nil, errors.New("host unreachable")
+}
+
+func TestConnectBacksOff(t *testing.T) {
+	dialer := &countingDialer{}
+	c := newSshConnector("unreachable:22", "user", "password", 1)
+	c.SetInsecureIgnoreHostKey()
+	c.dialer = dialer
+
+	for i, wantBackoff := range []time.Duration{minConnectBackoff, 2 * minConnectBackoff, 4 * minConnectBackoff} {
+		_, err := c.dialSsh(context.Background())
+		if !errors.Is(err, ErrConnect) {
+			t.Fatalf("attempt %d: got error %v, want ErrConnect", i+1, err)
+		}
+		if dialer.dials != i+1 {
+			t.Fatalf("attempt %d: dialed %d times", i+1, dialer.dials)
+		}
+
+		// Until the backoff ends, no connection is attempted.
+		_, err = c.dialSsh(context.Background())
+		var backoff *BackoffError
+		if !errors.As(err, &backoff) || !errors.Is(err, ErrConnect) {
+			t.Fatalf("attempt %d: got error %v during backoff, want a BackoffError wrapping ErrConnect", i+1, err)
+		}
+		if dialer.dials != i+1 {
+			t.Fatalf("attempt %d: dialed during backoff", i+1)
+		}
+		if wait := time.Until(backoff.Until); wait <= wantBackoff-time.Second/2 || wait > wantBackoff {
+			t.Errorf("attempt %d: backing off for %v, want %v", i+1, wait, wantBackoff)
+		}
+
+		c.retryConnectAt = time.Now() // skip the wait
+	}
+}
+
+// serveKeyboardInteractive runs an SSH server accepting one connection whose
+// user answers "Code: " with code.
+func serveKeyboardInteractive(t *testing.T, code string) net.Listener {
+	t.Helper()
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := ssh.NewSignerFromKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := &ssh.ServerConfig{
+		KeyboardInteractiveCallback: func(conn ssh.ConnMetadata, client ssh.KeyboardInteractiveChallenge) (*ssh.Permissions, error) {
+			answers, err := client("", "", []string{"Code: "}, []bool{false})
+			if err != nil {
+				return nil, err
+			}
+			if len(answers) != 1 || answers[0] != code {
+				return nil, errors.New("wrong code")
+			}
+			return &ssh.Permissions{}, nil
+		},
+	}
+	config.AddHostKey(signer)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		sshConn, chans, reqs, err := ssh.NewServerConn(conn, config)
+		if err != nil {
+			return
+		}
+		defer sshConn.Close()
+		go ssh.DiscardRequests(reqs)
+		for ch := range chans {
+			ch.Reject(ssh.Prohibited, "no channels")
+		}
+	}()
+	return ln
+}
+
+func TestPromptOutlastsRequestTimeout(t *testing.T) {
+	ln := serveKeyboardInteractive(t, "123456")
+	c := newSshConnector(ln.Addr().String(), "user", "", 1)
+	c.SetInsecureIgnoreHostKey()
+	c.SetPrompt(func(instruction string, question string, echo bool) (string, error) {
+		time.Sleep(1500 * time.Millisecond) // the user typing, longer than the timeout
+		return "123456", nil
+	})
+
+	client, err := c.dialSsh(context.Background())
+	if err != nil {
+		t.Fatalf("dialSsh: %v", err)
+	}
+	client.Close()
+}
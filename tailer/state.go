@@ -0,0 +1,214 @@
+package tailer
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+const stateVersion = 1
+
+// stateFile is the JSON document stored in the checkpoint file.
+type stateFile struct {
+	Version int              `json:"version"`
+	Offset  int64            `json:"offset"`
+	Files   map[string]int64 `json:"files,omitempty"`
+	ETag    string           `json:"etag,omitempty"`
+	Cursor  string           `json:"cursor,omitempty"`
+	Target  string           `json:"target,omitempty"`
+	Time    *time.Time       `json:"time,omitempty"`
+	// How far compressed files were read, by size and modification time.
+	Compressed []compressedState `json:"compressed,omitempty"`
+}
+
+// TailerBase holds the read position shared by all tailers and persists it.
+type TailerBase struct {
+	identity      string // names the followed source, without credentials
+	stateFilePath string
+	lastOffset    int64
+	offsets       map[string]int64 // per-file offsets when following several files
+	etag          string
+	cursor        string // opaque position for sources paginated by the server
+	linkTarget    string // file a followed symlink pointed to, "" if not known
+	lastTime      time.Time
+	eventHandler  EventHandler
+	limiter       *rate.Limiter
+	onTruncate    TruncatePolicy
+	sizes         map[string]fileSize // by path, "" for the file at lastOffset
+	wait          waitForFile
+	compressed    map[fileStamp]compressedRead
+	stampsSeen    map[fileStamp]bool // compressed files seen since the last pruneCompressed
+}
+
+// SetStateDir stores the state in dir, in a file named after a hash of the
+// source, so many sources can share one directory.
+func (t *TailerBase) SetStateDir(dir string) error {
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return fmt.Errorf("could not create state directory: %v", err)
+	}
+	sum := sha256.Sum256([]byte(t.identity))
+	t.stateFilePath = filepath.Join(dir, hex.EncodeToString(sum[:16])+".json")
+	return nil
+}
+
+// Offset returns the read position, summed over all files when following
+// several.
+func (t *TailerBase) Offset() int64 {
+	offset := t.lastOffset
+	for _, fileOffset := range t.offsets {
+		offset += fileOffset
+	}
+	return offset
+}
+
+// SetCheckpointTime keeps ts, the timestamp of the last line output, in the
+// state, for resuming by time where offsets can't be relied on.
+func (t *TailerBase) SetCheckpointTime(ts time.Time) {
+	t.lastTime = ts
+}
+
+// CheckpointTime returns the time given to SetCheckpointTime, as loaded from
+// the state. It is zero if none was kept.
+func (t *TailerBase) CheckpointTime() time.Time {
+	return t.lastTime
+}
+
+func (t *TailerBase) Rewind(lines []Line) error {
+	if len(lines) == 0 {
+		return nil
+	}
+	if lines[0].Offset > t.lastOffset {
+		return fmt.Errorf("cannot rewind to offset %d from offset %d", lines[0].Offset, t.lastOffset)
+	}
+	t.lastOffset = lines[0].Offset
+	return nil
+}
+
+// LoadState reads the saved position and reports whether a state file was
+// found.
+func (t *TailerBase) LoadState() (bool, error) {
+	if t.stateFilePath == "" {
+		t.lastOffset = 0
+		return false, nil
+	}
+	data, err := os.ReadFile(t.stateFilePath)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			t.lastOffset = 0
+			return false, nil
+		}
+		return false, fmt.Errorf("could not read checkpoint file: %v", err)
+	}
+
+	var state stateFile
+	if strings.HasPrefix(strings.TrimSpace(string(data)), "{") {
+		if err := json.Unmarshal(data, &state); err != nil {
+			return false, fmt.Errorf("invalid checkpoint file: %v", err)
+		}
+		if state.Version != stateVersion {
+			return false, fmt.Errorf("unsupported checkpoint file version: %d", state.Version)
+		}
+	} else {
+		state, err = parseLegacyState(string(data))
+		if err != nil {
+			return false, err
+		}
+	}
+
+	if state.Offset < 0 {
+		return false, fmt.Errorf("invalid offset in checkpoint file: %d", state.Offset)
+	}
+	for path, offset := range state.Files {
+		if offset < 0 {
+			return false, fmt.Errorf("invalid offset in checkpoint file for %s: %d", path, offset)
+		}
+	}
+
+	t.lastOffset = state.Offset
+	t.offsets = state.Files
+	t.etag = state.ETag
+	t.cursor = state.Cursor
+	t.linkTarget = state.Target
+	t.compressed = nil
+	for _, read := range state.Compressed {
+		t.markRead(read.Path, fileStamp{size: read.Size, modTime: read.ModTime.UnixNano()}, read.Offset, read.Complete)
+	}
+	t.lastTime = time.Time{}
+	if state.Time != nil {
+		t.lastTime = *state.Time
+	}
+	return true, nil
+}
+
+// parseLegacyState reads the plain-text format written by older versions:
+// just the offset on a line of its own. It is rewritten as JSON on the next
+// SaveState.
+func parseLegacyState(data string) (stateFile, error) {
+	offset, err := strconv.ParseInt(strings.TrimSpace(data), 10, 64)
+	if err != nil {
+		return stateFile{}, fmt.Errorf("invalid checkpoint file")
+	}
+	return stateFile{Version: stateVersion, Offset: offset}, nil
+}
+
+func (t *TailerBase) SaveState() error {
+	snapshot, err := t.SnapshotState()
+	if err != nil {
+		return err
+	}
+	return snapshot.Save()
+}
+
+// StateSnapshot is the state captured at one point, so it can be saved later,
+// once the lines read until then have been written out.
+type StateSnapshot struct {
+	path string
+	data []byte
+}
+
+func (t *TailerBase) SnapshotState() (StateSnapshot, error) {
+	if t.stateFilePath == "" {
+		return StateSnapshot{}, nil
+	}
+	var lastTime *time.Time
+	if !t.lastTime.IsZero() {
+		lastTime = &t.lastTime
+	}
+	var compressed []compressedState
+	for stamp, read := range t.compressed {
+		compressed = append(compressed, compressedState{Path: read.path, Size: stamp.size, ModTime: time.Unix(0, stamp.modTime).UTC(), Offset: read.offset, Complete: read.complete})
+	}
+	slices.SortFunc(compressed, func(a, b compressedState) int { return strings.Compare(a.Path, b.Path) })
+	data, err := json.Marshal(stateFile{
+		Version:    stateVersion,
+		Offset:     t.lastOffset,
+		Files:      t.offsets,
+		ETag:       t.etag,
+		Cursor:     t.cursor,
+		Target:     t.linkTarget,
+		Time:       lastTime,
+		Compressed: compressed,
+	})
+	if err != nil {
+		return StateSnapshot{}, err
+	}
+	return StateSnapshot{path: t.stateFilePath, data: append(data, '\n')}, nil
+}
+
+func (s StateSnapshot) Save() error {
+	if s.path == "" {
+		return nil
+	}
+	return os.WriteFile(s.path, s.data, 0644)
+}
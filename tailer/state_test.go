@@ -0,0 +1,18 @@
+package tailer
+
+import "testing"
+
+func TestParseLegacyState(t *testing.T) {
+	state, err := parseLegacyState("1234\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state.Offset != 1234 || state.Version != stateVersion {
+		t.Errorf("got %+v", state)
+	}
+	for _, data := range []string{"", "abc\n", "1234 /logs/app.log\n", "1234\n5 /logs/app.log\n"} {
+		if _, err := parseLegacyState(data); err == nil {
+			t.Errorf("%q: accepted", data)
+		}
+	}
+}
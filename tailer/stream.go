@@ -0,0 +1,139 @@
+package tailer
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// StreamTailer follows an HTTP endpoint that behaves like "tail -f" itself:
+// the response to a single GET never ends and carries new lines as they
+// appear. The offset counts the bytes received, so it only tells how much has
+// been consumed. When the stream ends, fails or stays idle for too long, it is
+// opened again on the next poll; lines sent meanwhile may be missed.
+type StreamTailer struct {
+	TailerBase
+	httpConnector
+
+	idleTimeout time.Duration
+	body        io.ReadCloser
+	cancel      context.CancelFunc
+	receiver    *receiver[byte]
+}
+
+func NewStreamTailer(url string, requestTimeoutSec int, stateFilePath string) *StreamTailer {
+	return &StreamTailer{
+		TailerBase: TailerBase{
+			identity:      redactURL(url),
+			stateFilePath: stateFilePath,
+			lastOffset:    0,
+		},
+		httpConnector: newHttpConnector(url, requestTimeoutSec),
+	}
+}
+
+// SetIdleTimeout makes the tailer reconnect when nothing was received for d,
+// which detects connections dropped without notice. 0 disables it.
+func (t *StreamTailer) SetIdleTimeout(d time.Duration) {
+	t.idleTimeout = d
+}
+
+func (t *StreamTailer) start(ctx context.Context) error {
+	// The stream outlives this call, so it gets its own context. Only waiting
+	// for the response headers is bounded by the request timeout.
+	streamCtx, cancel := context.WithCancel(context.Background())
+	timer := time.AfterFunc(time.Duration(t.requestTimeoutSec)*time.Second, cancel)
+	stopAbort := context.AfterFunc(ctx, cancel)
+
+	req, err := t.newRequest(streamCtx, "GET", nil)
+	if err != nil {
+		cancel()
+		return err
+	}
+	resp, err := t.do(req)
+	timer.Stop()
+	stopAbort()
+	if err != nil {
+		cancel()
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		cancel()
+		return statusError(resp)
+	}
+
+	t.body = resp.Body
+	t.cancel = cancel
+	t.receiver = receiveBytes(t.limitReader(streamCtx, resp.Body))
+	return nil
+}
+
+func (t *StreamTailer) stop() {
+	if t.cancel != nil {
+		t.cancel()
+		t.cancel = nil
+	}
+	if t.body != nil {
+		t.body.Close()
+		t.body = nil
+		<-t.receiver.done
+	}
+}
+
+func (t *StreamTailer) Close() error {
+	t.stop()
+	return t.httpConnector.Close()
+}
+
+func (t *StreamTailer) SetPosition(ctx context.Context, offset int64, whence int) error {
+	if offset == 0 && whence == io.SeekEnd {
+		return nil // only new data is ever read
+	}
+	return fmt.Errorf("cannot change position in a stream")
+}
+
+func (t *StreamTailer) CheckPosition(ctx context.Context) error {
+	return nil
+}
+
+func (t *StreamTailer) SetPositionLastLines(ctx context.Context, n int) error {
+	return fmt.Errorf("cannot start at the last lines of a stream")
+}
+
+func (t *StreamTailer) SetPositionAtTime(ctx context.Context, since time.Time, layout string) error {
+	return fmt.Errorf("cannot change position in a stream")
+}
+
+func (t *StreamTailer) Rewind(lines []Line) error {
+	return fmt.Errorf("cannot rewind a stream")
+}
+
+func (t *StreamTailer) FetchNewLines(ctx context.Context) ([]Line, error) {
+	if t.body == nil {
+		err := t.start(ctx)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	lines, closed, closeErr := takeLines(t.receiver, &t.lastOffset)
+	switch {
+	case closed:
+		t.stop()
+		if closeErr != nil {
+			t.emitEvent(EventStreamClosed, "", "Stream failed: %v. Reconnecting.", closeErr)
+		} else {
+			t.emitEvent(EventStreamClosed, "", "Stream ended. Reconnecting.")
+		}
+	case t.idleTimeout > 0 && t.receiver.idle() > t.idleTimeout:
+		t.stop()
+		t.emitEvent(EventStreamClosed, "", "Nothing received for %v. Reconnecting.", t.idleTimeout)
+	}
+	return lines, nil
+}
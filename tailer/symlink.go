@@ -0,0 +1,73 @@
+package tailer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+)
+
+// maxSymlinks bounds how many symlinks are followed resolving a path, as
+// loops never resolve.
+const maxSymlinks = 40
+
+// SetFollowSymlink makes the tailer resolve the followed path on every poll,
+// for a symlink like "current" that is pointed at a new file on rotation.
+// When it points to another file, the rest of the old one is read and the
+// tailer switches to the new one from its start. The file pointed to is kept
+// in the state, so a rotation while not running is noticed too. Without it,
+// the path is opened as is and a repointed symlink looks like the file was
+// truncated or grew.
+func (t *SftpTailer) SetFollowSymlink(followLink bool) {
+	t.followLink = followLink
+}
+
+// fetchSymlink tails the file the symlink pointed to, switching to the one
+// it points to now once the old one was read to its end.
+func (t *SftpTailer) fetchSymlink(ctx context.Context) ([]Line, error) {
+	target, err := t.resolveSymlinks(t.filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve %s: %w", t.filePath, err)
+	}
+	if t.linkTarget == "" {
+		t.linkTarget = target // the position is in the file pointed to now
+	}
+
+	lines, err := t.fetchFile(ctx, t.linkTarget, &t.lastOffset)
+	gone := errors.Is(err, os.ErrNotExist)
+	if err != nil && !gone || target == t.linkTarget {
+		return lines, err
+	}
+	if !gone && !t.readToEnd(t.linkTarget, t.lastOffset) {
+		return lines, nil // the rest of the old file comes first
+	}
+
+	t.emitEvent(EventFileSwitched, target, "Symlink %s now points to %s, switching from %s.", t.filePath, target, t.linkTarget)
+	t.linkTarget, t.lastOffset = target, 0
+	newLines, err := t.fetchFile(ctx, target, &t.lastOffset)
+	return append(lines, newLines...), err
+}
+
+// resolveSymlinks returns the file p points to through any number of
+// symlinks.
+func (t *SftpTailer) resolveSymlinks(p string) (string, error) {
+	for range maxSymlinks {
+		info, err := t.client.Lstat(p)
+		if err != nil {
+			return "", err
+		}
+		if info.Mode()&os.ModeSymlink == 0 {
+			return p, nil
+		}
+		link, err := t.client.ReadLink(p)
+		if err != nil {
+			return "", err
+		}
+		if !path.IsAbs(link) {
+			link = path.Join(path.Dir(p), link)
+		}
+		p = link
+	}
+	return "", fmt.Errorf("too many levels of symlinks")
+}
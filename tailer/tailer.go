@@ -0,0 +1,64 @@
+// Package tailer follows remote log files over HTTP(S) and SFTP, returning
+// only the lines appended since the previous poll.
+package tailer
+
+import (
+	"context"
+	"time"
+)
+
+// Line is a line returned by a tailer, without its newline.
+type Line struct {
+	Text string
+	// Offset is where the line starts: in its file or in the data received
+	// from a stream. Query results are counted in lines instead.
+	Offset int64
+	// Source is the file the line comes from, where the tailer follows
+	// several files or knows the path.
+	Source string
+}
+
+type Tailer interface {
+	// FetchNewLines returns the complete lines appended since the last call.
+	// When reading fails midway it returns the lines read so far along with
+	// the error, the position is advanced past them.
+	FetchNewLines(ctx context.Context) ([]Line, error)
+	// SetPosition moves the read position, whence is io.SeekStart or io.SeekEnd.
+	SetPosition(ctx context.Context, offset int64, whence int) error
+	// SetPositionLastLines moves the read position to the start of the last
+	// n complete lines.
+	SetPositionLastLines(ctx context.Context, n int) error
+	// SetPositionAtTime moves the read position to the first line whose
+	// leading timestamp, parsed with layout, is at or after since.
+	SetPositionAtTime(ctx context.Context, since time.Time, layout string) error
+	// Offset returns the current read position.
+	Offset() int64
+	// Rewind moves the read position back to the start of lines, the last
+	// ones returned by FetchNewLines, so that the next FetchNewLines returns
+	// them again. Sources whose data can't be read again return an error.
+	Rewind(lines []Line) error
+	SetStateDir(dir string) error
+	// LoadState reads the saved position and reports whether there was one.
+	LoadState() (bool, error)
+	// CheckPosition resets a loaded position that is beyond the end of the
+	// file, as when the state comes from another environment or the file was
+	// replaced by a smaller one. Sources without a size ignore it.
+	CheckPosition(ctx context.Context) error
+	SaveState() error
+	// SnapshotState captures the current state without writing it.
+	SnapshotState() (StateSnapshot, error)
+	SetEventHandler(handler EventHandler)
+	// SetRateLimit throttles reading new data to bytesPerSec, 0 removes the
+	// limit.
+	SetRateLimit(bytesPerSec int)
+	SetTruncatePolicy(policy TruncatePolicy)
+	// Size returns the size of the followed files as last seen and their
+	// latest modification time, ok is false where the size is unknown.
+	Size() (size int64, modTime time.Time, ok bool)
+	// SetCheckpointTime keeps the timestamp of the last line output in the
+	// state, CheckpointTime returns it.
+	SetCheckpointTime(ts time.Time)
+	CheckpointTime() time.Time
+	// Close releases any connection held by the tailer.
+	Close() error
+}
@@ -0,0 +1,106 @@
+package tailer
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"time"
+)
+
+// TcpTailer connects to a TCP port that pushes log lines, netcat style, and
+// keeps the connection open. The offset counts the bytes received, so it
+// only tells how much has been consumed. When the connection drops, it is
+// opened again on the next poll; lines sent meanwhile may be missed.
+type TcpTailer struct {
+	TailerBase
+
+	address           string
+	requestTimeoutSec int
+	conn              net.Conn
+	receiver          *receiver[byte]
+}
+
+func NewTcpTailer(address string, requestTimeoutSec int, stateFilePath string) *TcpTailer {
+	return &TcpTailer{
+		TailerBase: TailerBase{
+			identity:      "tcp://" + address,
+			stateFilePath: stateFilePath,
+			lastOffset:    0,
+		},
+		address:           address,
+		requestTimeoutSec: requestTimeoutSec,
+	}
+}
+
+func (t *TcpTailer) start(ctx context.Context) error {
+	dialer := net.Dialer{Timeout: time.Duration(t.requestTimeoutSec) * time.Second}
+	conn, err := dialer.DialContext(ctx, "tcp", t.address)
+	if err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return classify(ErrConnect, err)
+	}
+
+	// The connection outlives this call, so reading it gets its own context.
+	t.conn = conn
+	t.receiver = receiveBytes(t.limitReader(context.Background(), conn))
+	return nil
+}
+
+func (t *TcpTailer) stop() {
+	if t.conn != nil {
+		t.conn.Close()
+		t.conn = nil
+		<-t.receiver.done
+	}
+}
+
+func (t *TcpTailer) Close() error {
+	t.stop()
+	return nil
+}
+
+func (t *TcpTailer) SetPosition(ctx context.Context, offset int64, whence int) error {
+	if offset == 0 && whence == io.SeekEnd {
+		return nil // only new data is ever read
+	}
+	return fmt.Errorf("cannot change position in a TCP stream")
+}
+
+func (t *TcpTailer) CheckPosition(ctx context.Context) error {
+	return nil
+}
+
+func (t *TcpTailer) SetPositionLastLines(ctx context.Context, n int) error {
+	return fmt.Errorf("cannot start at the last lines of a TCP stream")
+}
+
+func (t *TcpTailer) SetPositionAtTime(ctx context.Context, since time.Time, layout string) error {
+	return fmt.Errorf("cannot change position in a TCP stream")
+}
+
+func (t *TcpTailer) Rewind(lines []Line) error {
+	return fmt.Errorf("cannot rewind a TCP stream")
+}
+
+func (t *TcpTailer) FetchNewLines(ctx context.Context) ([]Line, error) {
+	if t.conn == nil {
+		err := t.start(ctx)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	lines, closed, closeErr := takeLines(t.receiver, &t.lastOffset)
+	if closed {
+		t.stop()
+		if closeErr != nil {
+			t.emitEvent(EventStreamClosed, "", "Connection to %s failed: %v. Reconnecting.", t.address, closeErr)
+		} else {
+			t.emitEvent(EventStreamClosed, "", "Connection to %s closed. Reconnecting.", t.address)
+		}
+	}
+	return lines, nil
+}
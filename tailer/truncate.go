@@ -0,0 +1,41 @@
+package tailer
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// TruncatePolicy tells what a tailer does when a file became shorter than
+// the read position, as after it was truncated or replaced.
+type TruncatePolicy string
+
+const (
+	TruncateReset TruncatePolicy = "reset" // read the file again from the start
+	TruncateSkip  TruncatePolicy = "skip"  // go on from the new end of the file
+	TruncateError TruncatePolicy = "error" // fail with ErrTruncated
+)
+
+// SetTruncatePolicy sets how truncated files are handled, by default with
+// TruncateReset.
+func (t *TailerBase) SetTruncatePolicy(policy TruncatePolicy) {
+	t.onTruncate = policy
+}
+
+// truncated moves *offset according to the truncate policy after a file
+// shrank to size bytes, which is only needed with TruncateSkip. The message
+// describes what happened.
+func (t *TailerBase) truncated(path string, size int64, offset *int64, format string, args ...any) error {
+	message := fmt.Sprintf(format, args...)
+	switch t.onTruncate {
+	case TruncateSkip:
+		t.emitEvent(EventTruncated, path, "%s Continuing at the end.", message)
+		*offset = size
+	case TruncateError:
+		return classify(ErrTruncated, errors.New(strings.TrimSuffix(message, ".")))
+	default:
+		t.emitEvent(EventTruncated, path, "%s Resetting state.", message)
+		*offset = 0
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package tailer
+
+import (
+	"context"
+	"errors"
+	"os"
+)
+
+// waitForFile is what SetWaitForFile arms: a missing file is not an error but
+// one that doesn't exist yet.
+type waitForFile struct {
+	enabled bool
+	missing bool                            // the file was missing when last tried
+	start   func(ctx context.Context) error // start position put off until the file exists
+}
+
+// SetWaitForFile makes a missing file count as not created yet, e.g. when
+// the tailer starts before the application writing the log: polls return no
+// lines until the file appears, and a start position needing the file, like
+// its end, is applied once it does.
+func (t *TailerBase) SetWaitForFile(wait bool) {
+	t.wait.enabled = wait
+}
+
+// startWhenFound applies a start position, putting it off until the file
+// exists when waiting for it.
+func (t *TailerBase) startWhenFound(ctx context.Context, start func(ctx context.Context) error) error {
+	err := start(ctx)
+	if t.waitingFor(err) {
+		t.wait.start = start
+		return nil
+	}
+	t.wait.start = nil
+	return err
+}
+
+// fileReady applies the start position put off by startWhenFound. It
+// returns false while the file is still missing.
+func (t *TailerBase) fileReady(ctx context.Context) (bool, error) {
+	if t.wait.start == nil {
+		return true, nil
+	}
+	err := t.wait.start(ctx)
+	if t.waitingFor(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	t.wait.start = nil
+	t.found()
+	return true, nil
+}
+
+// waitingFor tells whether err is about the file missing while waiting for
+// it, reporting when it went missing.
+func (t *TailerBase) waitingFor(err error) bool {
+	if !t.wait.enabled || !errors.Is(err, os.ErrNotExist) {
+		return false
+	}
+	if !t.wait.missing {
+		t.wait.missing = true
+		t.emitEvent(EventWaitingForFile, "", "%s does not exist, waiting for it.", t.identity)
+	}
+	return true
+}
+
+// found reports that a missing file appeared.
+func (t *TailerBase) found() {
+	if t.wait.missing {
+		t.wait.missing = false
+		t.emitEvent(EventFileAppeared, "", "%s appeared.", t.identity)
+	}
+}
@@ -0,0 +1,310 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/prokoma/remote-tail-f/tailer"
+)
+
+// targetOptions are the options that can be set per line of -targets-file.
+// They are only read while creating a tailer.
+var targetOptions = map[string]bool{
+	"accept-gzip":         true,
+	"chunk-bytes":         true,
+	"chunk-segments":      true,
+	"decompress":          true,
+	"exec-command":        true,
+	"failover-after":      true,
+	"grpc-ca-cert":        true,
+	"follow-symlink":      true,
+	"http-version":        true,
+	"idle-conn-timeout":   true,
+	"insecure":            true,
+	"journal-command":     true,
+	"max-idle-conns":      true,
+	"newest":              true,
+	"query-body":          true,
+	"query-cursor-path":   true,
+	"query-lines-path":    true,
+	"read-timeout-sec":    true,
+	"request-id-header":   true,
+	"request-timeout-sec": true,
+	"sftp-idle-close-sec": true,
+	"sftp-proxy":          true,
+	"ssh-ciphers":         true,
+	"ssh-host-key-algos":  true,
+	"ssh-keepalive-sec":   true,
+	"ssh-kex":             true,
+	"ssh-macs":            true,
+	"ssh-socket":          true,
+	"stream":              true,
+	"stream-idle-timeout": true,
+	"user-agent":          true,
+	"wait-for-file":       true,
+}
+
+// target is one source to follow: a URL with optional fallback URLs and
+// options overriding the command line ones.
+type target struct {
+	key     string // identifies the target across reloads
+	urls    []string
+	options map[string]string
+}
+
+// readTargets reads a targets file, "-" meaning stdin. Each line holds
+// "URL [FALLBACK_URL...] [-option=value...]", with values in double quotes
+// when they contain spaces. Blank lines and lines starting with # are skipped.
+func readTargets(path string) ([]target, error) {
+	var r io.Reader = os.Stdin
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, fmt.Errorf("could not read targets: %v", err)
+		}
+		defer f.Close()
+		r = f
+	}
+
+	var targets []target
+	seen := map[string]bool{}
+	scanner := bufio.NewScanner(r)
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		tg, err := parseTarget(line)
+		if err != nil {
+			return nil, fmt.Errorf("invalid target on line %d of %s: %v", lineNum, path, err)
+		}
+		if !seen[tg.key] {
+			seen[tg.key] = true
+			targets = append(targets, tg)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("could not read targets: %v", err)
+	}
+	if len(targets) == 0 {
+		return nil, fmt.Errorf("no targets in %s", path)
+	}
+	return targets, nil
+}
+
+// String lists the URLs of tg without passwords.
+func (tg target) String() string {
+	urls := make([]string, len(tg.urls))
+	for i, rawURL := range tg.urls {
+		urls[i] = rawURL
+		if u, err := url.Parse(rawURL); err == nil {
+			urls[i] = u.Redacted()
+		}
+	}
+	return strings.Join(urls, " ")
+}
+
+func parseTarget(line string) (target, error) {
+	fields, err := splitFields(line)
+	if err != nil {
+		return target{}, err
+	}
+	tg := target{options: map[string]string{}}
+	for _, field := range fields {
+		option, ok := strings.CutPrefix(field, "-")
+		if !ok {
+			tg.urls = append(tg.urls, field)
+			continue
+		}
+		name, value, hasValue := strings.Cut(strings.TrimPrefix(option, "-"), "=")
+		f := flag.Lookup(name)
+		if f == nil || !targetOptions[name] {
+			return target{}, fmt.Errorf("option -%s cannot be set per target", name)
+		}
+		if !hasValue {
+			if boolFlag, ok := f.Value.(interface{ IsBoolFlag() bool }); !ok || !boolFlag.IsBoolFlag() {
+				return target{}, fmt.Errorf("option -%s needs a value", name)
+			}
+			value = "true"
+		}
+		tg.options[name] = value
+	}
+	if len(tg.urls) == 0 {
+		return target{}, fmt.Errorf("missing URL")
+	}
+	tg.key = strings.Join(fields, " ")
+	return tg, nil
+}
+
+// splitFields splits line at spaces outside double quotes. Inside quotes \"
+// stands for a quote and \\ for a backslash.
+func splitFields(line string) ([]string, error) {
+	var fields []string
+	var field strings.Builder
+	inField, quoted := false, false
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case quoted && c == '\\' && i+1 < len(line) && (line[i+1] == '"' || line[i+1] == '\\'):
+			i++
+			field.WriteByte(line[i])
+		case c == '"':
+			quoted = !quoted
+			inField = true
+		case !quoted && (c == ' ' || c == '\t'):
+			if inField {
+				fields = append(fields, field.String())
+				field.Reset()
+				inField = false
+			}
+		default:
+			field.WriteByte(c)
+			inField = true
+		}
+	}
+	if quoted {
+		return nil, fmt.Errorf("unterminated quote")
+	}
+	if inField {
+		fields = append(fields, field.String())
+	}
+	return fields, nil
+}
+
+// createTargetTailer creates the tailer for tg with its options in effect.
+func createTargetTailer(tg target) (tailer.Tailer, error) {
+	saved := map[string]string{}
+	defer func() {
+		for name, value := range saved {
+			flag.Set(name, value)
+		}
+	}()
+	for name, value := range tg.options {
+		saved[name] = flag.Lookup(name).Value.String()
+		err := flag.Set(name, value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid -%s: %v", name, err)
+		}
+	}
+	return createTailers(tg.urls)
+}
+
+// fleet runs a fetch loop for every followed target, all of them sending to
+// the same output.
+type fleet struct {
+	ctx      context.Context
+	path     string // targets file re-read on reload
+	batches  chan batch
+	status   *runStatus
+	running  map[string]*follower
+	finished chan *follower
+	ended    []tailer.Tailer // tailers whose loop ended on its own, their state is saved on exit
+
+	mu       sync.Mutex
+	exitCode int
+}
+
+type follower struct {
+	key     string
+	target  target
+	tailer  tailer.Tailer
+	cancel  context.CancelFunc
+	removed bool
+}
+
+func newFleet(ctx context.Context, path string, batches chan batch, status *runStatus) *fleet {
+	return &fleet{
+		ctx:      ctx,
+		path:     path,
+		batches:  batches,
+		status:   status,
+		running:  map[string]*follower{},
+		finished: make(chan *follower),
+	}
+}
+
+func (f *fleet) start(tg target, t tailer.Tailer) {
+	ctx, cancel := context.WithCancel(f.ctx)
+	fl := &follower{key: tg.key, target: tg, tailer: t, cancel: cancel}
+	f.running[tg.key] = fl
+	go func() {
+		exitCode := fetchLoop(ctx, tg.String(), t, f.batches, f.status)
+		f.mu.Lock()
+		if exitCode != 0 {
+			f.exitCode = exitCode
+		}
+		f.mu.Unlock()
+		f.finished <- fl
+	}()
+}
+
+// run supervises the fetch loops until all of them ended, re-reading the
+// targets file on every value from reload. It closes batches when done.
+func (f *fleet) run(reload <-chan struct{}) {
+	defer close(f.batches)
+	for len(f.running) > 0 {
+		select {
+		case fl := <-f.finished:
+			fl.cancel()
+			if f.running[fl.key] == fl {
+				delete(f.running, fl.key)
+			}
+			if fl.removed {
+				f.status.mu.Lock()
+				delete(f.status.offsets, fl.target.String())
+				f.status.mu.Unlock()
+				fl.tailer.Close()
+			} else {
+				f.ended = append(f.ended, fl.tailer)
+			}
+		case <-reload:
+			f.reload()
+		}
+	}
+}
+
+// reload starts following the targets added to the targets file and stops
+// following the removed ones.
+func (f *fleet) reload() {
+	if f.path == "-" {
+		fmt.Fprintf(os.Stderr, "Targets read from stdin cannot be reloaded\n")
+		return
+	}
+	targets, err := readTargets(f.path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to reload targets: %v\n", err)
+		return
+	}
+	warnInsecure(targets)
+	keep := map[string]bool{}
+	for _, tg := range targets {
+		keep[tg.key] = true
+		if fl, ok := f.running[tg.key]; ok && !fl.removed {
+			continue
+		}
+		t, exitCode := startTarget(f.ctx, tg, f.status)
+		if exitCode != 0 {
+			continue
+		}
+		if !*quiet {
+			fmt.Fprintf(os.Stderr, "Following %s\n", tg)
+		}
+		f.start(tg, t)
+	}
+	for key, fl := range f.running {
+		if !keep[key] && !fl.removed {
+			if !*quiet {
+				fmt.Fprintf(os.Stderr, "Stopped following %s\n", fl.target)
+			}
+			fl.removed = true
+			fl.cancel()
+		}
+	}
+}
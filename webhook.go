@@ -0,0 +1,151 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+)
+
+const (
+	webhookAttempts      = 5
+	webhookRetryDelay    = time.Second
+	webhookMaxRetryDelay = time.Minute
+	webhookQueueBatches  = 8 // polls the queue holds before lines are dropped
+)
+
+// webhookLine is how a line is posted to the -webhook URL.
+type webhookLine struct {
+	Source string    `json:"source,omitempty"`
+	Offset int64     `json:"offset"`
+	Time   time.Time `json:"time"`
+	Line   string    `json:"line"`
+}
+
+// webhookOutput posts the lines of each poll to a URL as a JSON array. Posts
+// are made in the background, so a slow webhook doesn't hold up the output;
+// when the queue is full, lines are dropped instead. A failed post is
+// retried, waiting as long as a 429 response's Retry-After asks, up to
+// webhookMaxRetryDelay.
+type webhookOutput struct {
+	url    string
+	client *http.Client
+	status *runStatus
+
+	batches chan []webhookLine
+	done    chan struct{}
+}
+
+func newWebhookOutput(url string, timeout time.Duration, status *runStatus) *webhookOutput {
+	w := &webhookOutput{
+		url:     url,
+		client:  &http.Client{Timeout: timeout},
+		status:  status,
+		batches: make(chan []webhookLine, webhookQueueBatches),
+		done:    make(chan struct{}),
+	}
+	go w.run()
+	return w
+}
+
+// add queues lines for posting, dropping them when the queue is full.
+func (w *webhookOutput) add(lines []webhookLine) {
+	if len(lines) == 0 {
+		return
+	}
+	select {
+	case w.batches <- lines:
+	default:
+		fmt.Fprintf(os.Stderr, "Webhook is falling behind, dropped %d lines\n", len(lines))
+		w.countDropped(len(lines))
+	}
+}
+
+func (w *webhookOutput) run() {
+	defer close(w.done)
+	for lines := range w.batches {
+		w.post(lines)
+	}
+}
+
+// close posts what is still queued.
+func (w *webhookOutput) close() {
+	close(w.batches)
+	<-w.done
+}
+
+// post sends lines, giving up after webhookAttempts. Lines that could not be
+// delivered are counted as dropped.
+func (w *webhookOutput) post(lines []webhookLine) {
+	body, err := json.Marshal(lines)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to encode webhook lines: %v\n", err)
+		return
+	}
+	delay := webhookRetryDelay
+	for attempt := 1; ; attempt++ {
+		retryAfter, err := w.send(body)
+		if err == nil {
+			return
+		}
+		if attempt == webhookAttempts || retryAfter < 0 {
+			fmt.Fprintf(os.Stderr, "Failed to post %d lines to webhook: %v\n", len(lines), err)
+			w.countDropped(len(lines))
+			return
+		}
+		if retryAfter > 0 {
+			delay = min(retryAfter, webhookMaxRetryDelay)
+		}
+		fmt.Fprintf(os.Stderr, "Failed to post to webhook, retrying in %v: %v\n", delay, err)
+		<-clk.After(delay)
+		delay = min(delay*2, webhookMaxRetryDelay)
+	}
+}
+
+func (w *webhookOutput) countDropped(n int) {
+	w.status.mu.Lock()
+	w.status.droppedLines += int64(n)
+	w.status.mu.Unlock()
+}
+
+// send posts body once. On failure it returns how long the server asked to
+// wait, 0 when it didn't, or -1 when retrying is pointless.
+func (w *webhookOutput) send(body []byte) (time.Duration, error) {
+	req, err := http.NewRequest(http.MethodPost, w.url, bytes.NewReader(body))
+	if err != nil {
+		return -1, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", *userAgent)
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return 0, nil
+	case resp.StatusCode == http.StatusTooManyRequests:
+		return parseRetryAfter(resp.Header.Get("Retry-After")), fmt.Errorf("unexpected HTTP status: %s", resp.Status)
+	case resp.StatusCode >= 500:
+		return 0, fmt.Errorf("unexpected HTTP status: %s", resp.Status)
+	default:
+		return -1, fmt.Errorf("unexpected HTTP status: %s", resp.Status)
+	}
+}
+
+// parseRetryAfter reads a Retry-After header given in seconds or as a date.
+func parseRetryAfter(value string) time.Duration {
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		return max(date.Sub(clk.Now()), 0)
+	}
+	return 0
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestWebhookDoesNotHoldUpOutput(t *testing.T) {
+	release := make(chan struct{})
+	var mu sync.Mutex
+	var received []webhookLine
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		var lines []webhookLine
+		json.NewDecoder(r.Body).Decode(&lines)
+		mu.Lock()
+		received = append(received, lines...)
+		mu.Unlock()
+	}))
+	defer server.Close()
+	status := &runStatus{}
+	webhook := newWebhookOutput(server.URL, 10*time.Second, status)
+
+	// The first poll is being posted, the next webhookQueueBatches wait and
+	// the rest is dropped, all without waiting for the webhook.
+	start := time.Now()
+	for i := 0; i < webhookQueueBatches+3; i++ {
+		webhook.add([]webhookLine{{Offset: int64(i), Line: "line"}})
+		if i == 0 {
+			time.Sleep(100 * time.Millisecond) // let it start posting
+		}
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("queueing took %v", elapsed)
+	}
+	status.mu.Lock()
+	dropped := status.droppedLines
+	status.mu.Unlock()
+	if dropped != 2 {
+		t.Errorf("dropped %d lines, want 2", dropped)
+	}
+
+	close(release)
+	webhook.close()
+	mu.Lock()
+	defer mu.Unlock()
+	if len(received) != webhookQueueBatches+1 {
+		t.Errorf("received %d lines, want %d", len(received), webhookQueueBatches+1)
+	}
+}